@@ -0,0 +1,234 @@
+package clashx
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"net"
+	"strings"
+)
+
+//localHosts hosts 文件中指向本机的常见条目，转换时忽略.
+var localHosts = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
+
+//parseAdblock 解析 AdGuard / Adblock 格式的域名列表，只转换屏蔽整个域名的规则，
+// 带路径、通配符、正则以及限定资源类型的规则会被忽略，@@ 例外规则转换为 DIRECT 策略的条目.
+func parseAdblock(body []byte, behavior string) ([]string, error) {
+	var payload, exceptions, ignored []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") || strings.Contains(line, "#") {
+			continue
+		}
+		exception := strings.HasPrefix(line, "@@")
+		item, ok := parseAdblockRule(strings.TrimPrefix(line, "@@"))
+		switch {
+		case !ok && exception:
+			ignored = append(ignored, line)
+		case !ok:
+		case exception:
+			exceptions = append(exceptions, item)
+		default:
+			payload = append(payload, item)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	logIgnoredExceptions(FormatAdblock, ignored)
+	return compactDomainRules(payload, exceptions), nil
+}
+
+//parseAdblockRule 将一条 Adblock 规则转换为规则条目，无法转换时返回 false.
+func parseAdblockRule(line string) (string, bool) {
+	if i := strings.IndexByte(line, '$'); i >= 0 {
+		for _, option := range strings.Split(line[i+1:], ",") {
+			if option != "important" && option != "all" {
+				return "", false
+			}
+		}
+		line = line[:i]
+	}
+	domainType := RuleDomain
+	switch {
+	case strings.HasPrefix(line, "||"):
+		domainType = RuleDomainSuffix
+		line = line[2:]
+	case strings.HasPrefix(line, "|"):
+		//|http://host^ 只匹配该主机
+		line = line[1:]
+		if i := strings.Index(line, "://"); i >= 0 {
+			line = line[i+3:]
+		}
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "|"), "^")
+	if strings.ContainsAny(line, "/*|^:") {
+		return "", false
+	}
+	return hostRule(strings.ToLower(line), domainType)
+}
+
+//parseHosts 解析 hosts 格式的屏蔽列表，也支持每行一个域名的列表.
+func parseHosts(body []byte, behavior string) ([]string, error) {
+	var payload []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		} else if len(fields) > 1 {
+			continue
+		}
+		for _, host := range fields {
+			host = strings.ToLower(strings.TrimSuffix(host, "."))
+			if localHosts[host] || net.ParseIP(host) != nil {
+				continue
+			}
+			if item, ok := hostRule(host, RuleDomain); ok {
+				payload = append(payload, item)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return compactDomainRules(payload, nil), nil
+}
+
+//compactDomainRules 去除重复的条目、完全落在例外规则范围内的条目，以及已被上级域名的 DOMAIN-SUFFIX 覆盖的条目.
+// 落在列表条目范围内的例外规则会转换为 DIRECT 策略的条目并放在最前面，与列表条目无关的例外规则没有作用，会被忽略.
+func compactDomainRules(payload, exceptions []string) []string {
+	items := make(map[string]bool, len(payload))
+	suffixes := make(map[string]bool)
+	for _, item := range payload {
+		items[item] = true
+		if strings.HasPrefix(item, RuleDomainSuffix+",") {
+			suffixes[item[len(RuleDomainSuffix)+1:]] = true
+		}
+	}
+
+	//例外规则只在其范围与列表条目重叠时才需要保留
+	exceptionItems := make(map[string]bool, len(exceptions))
+	exceptionSuffixes := make(map[string]bool)
+	for _, item := range exceptions {
+		exceptionItems[item] = true
+		if strings.HasPrefix(item, RuleDomainSuffix+",") {
+			exceptionSuffixes[item[len(RuleDomainSuffix)+1:]] = true
+		}
+	}
+	//excepted 条目是否完全落在例外规则的范围内：与例外规则相同，或位于 DOMAIN-SUFFIX 例外规则之下.
+	// DOMAIN 例外规则只覆盖同一个域名，不会使 DOMAIN-SUFFIX 条目失效
+	excepted := func(t, domain string) bool {
+		if exceptionItems[t+","+domain] {
+			return true
+		}
+		if t != RuleDomain && t != RuleDomainSuffix {
+			return false
+		}
+		for ; domain != ""; domain = parentDomain(domain) {
+			if exceptionSuffixes[domain] {
+				return true
+			}
+		}
+		return false
+	}
+	overlapped := make(map[string]bool)
+	for _, item := range payload {
+		i := strings.IndexByte(item, ',')
+		if t := item[:i]; t != RuleDomain && t != RuleDomainSuffix {
+			continue
+		}
+		for domain := parentDomain(item[i+1:]); domain != ""; domain = parentDomain(domain) {
+			if exceptionSuffixes[domain] {
+				overlapped[domain] = true
+			}
+		}
+	}
+	rules := make([]string, 0, len(payload))
+	seen := make(map[string]bool, len(payload)+len(exceptions))
+	for _, item := range exceptions {
+		i := strings.IndexByte(item, ',')
+		t, domain := item[:i], item[i+1:]
+		covered := items[item]
+		if t == RuleDomain || t == RuleDomainSuffix {
+			covered = covered || items[RuleDomain+","+domain] || suffixes[domain] || (t == RuleDomainSuffix && overlapped[domain])
+			for parent := parentDomain(domain); parent != "" && !covered; parent = parentDomain(parent) {
+				covered = suffixes[parent]
+			}
+		}
+		if covered && !seen[item] {
+			seen[item] = true
+			rules = append(rules, item+","+exceptionPolicy)
+		}
+	}
+
+	for _, item := range payload {
+		i := strings.IndexByte(item, ',')
+		t, domain := item[:i], item[i+1:]
+		if seen[item] || excepted(t, domain) {
+			continue
+		}
+		seen[item] = true
+		if t == RuleDomain || t == RuleDomainSuffix {
+			parent := domain
+			if t == RuleDomainSuffix {
+				parent = parentDomain(domain)
+			}
+			covered := false
+			for ; parent != ""; parent = parentDomain(parent) {
+				if suffixes[parent] && !excepted(RuleDomainSuffix, parent) {
+					covered = true
+					break
+				}
+			}
+			if covered {
+				continue
+			}
+		}
+		rules = append(rules, item)
+	}
+	return rules
+}
+
+//logIgnoredExceptions 记录无法转换为 clash 规则的例外规则.
+func logIgnoredExceptions(format string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	examples := lines
+	if len(examples) > 5 {
+		examples = examples[:5]
+	}
+	log.Printf("%s: %d exception rules cannot be converted and are ignored -> %s", format, len(lines), strings.Join(examples, " "))
+}
+
+//parentDomain 返回上一级域名，没有上一级时返回空字符串.
+func parentDomain(domain string) string {
+	if i := strings.IndexByte(domain, '.'); i >= 0 {
+		return domain[i+1:]
+	}
+	return ""
+}
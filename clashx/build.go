@@ -0,0 +1,345 @@
+package clashx
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	defaultTestUrl      = "http://www.gstatic.com/generate_204"
+	defaultTestInterval = 300
+)
+
+//Options 生成配置时对节点的处理选项.
+type Options struct {
+	//只保留名称匹配该正则的节点
+	Include string `yaml:"include,omitempty" json:"include,omitempty"`
+	//排除名称匹配该正则的节点
+	Exclude string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+	//按顺序执行的节点重命名规则
+	Rename []*RenameRule `yaml:"rename,omitempty" json:"rename,omitempty"`
+	//节点名称前缀
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	//根据识别到的地区在节点名称前插入国旗
+	Emoji *bool `yaml:"emoji,omitempty" json:"emoji,omitempty"`
+	//节点排序方式：source / name / region / multiplier，默认保持订阅中的顺序
+	Sort string `yaml:"sort,omitempty" json:"sort,omitempty"`
+	//按地区排序时的地区顺序，可以使用地区代码或名称
+	RegionOrder []string `yaml:"region-order,omitempty" json:"region_order,omitempty"`
+	//按正则自动生成的地区分组
+	RegionGroups []*RegionGroup `yaml:"region-groups,omitempty" json:"region_groups,omitempty"`
+	//额外的分组，与模板中同名的分组会被替换
+	Groups []*ProxyGroup `yaml:"groups,omitempty" json:"groups,omitempty"`
+	//将模板中引用的全部规则集展开为普通规则，不设置时只展开标记了 inline 的规则集，其余规则集由本服务转换为 clash 格式后提供
+	InlineRuleSets *bool `yaml:"inline-rule-sets,omitempty" json:"inline_rule_sets,omitempty"`
+	//移除因前面存在范围更大的规则而永远不会被匹配的规则，完全重复的规则总是会被移除
+	CollapseRules *bool `yaml:"collapse-rules,omitempty" json:"collapse_rules,omitempty"`
+	//合并多个订阅来源时，为每个来源生成一个以来源名称命名的 select 分组并加入主分组
+	SourceGroups *bool `yaml:"source-groups,omitempty" json:"source_groups,omitempty"`
+	//在主分组中加入展示剩余流量和到期时间的节点
+	InfoNodes *bool `yaml:"info-nodes,omitempty" json:"info_nodes,omitempty"`
+	//代理链设置，匹配的节点经由指定的前置代理连接
+	Chains []*ProxyChain `yaml:"chains,omitempty" json:"chains,omitempty"`
+}
+
+//RenameRule 节点重命名规则，将名称中匹配 Pattern 的部分替换为 Replace，Replace 中可以使用 $1 等引用分组.
+type RenameRule struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Replace string `yaml:"replace" json:"replace"`
+}
+
+//RegionGroup 按节点名称自动生成的分组，匹配 Pattern 的节点会加入该分组.
+type RegionGroup struct {
+	Name    string `yaml:"name" json:"name"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+	//分组类型：select / url-test / fallback / load-balance，默认为 select
+	Type     string `yaml:"type,omitempty" json:"type,omitempty"`
+	Url      string `yaml:"url,omitempty" json:"url,omitempty"`
+	Interval int    `yaml:"interval,omitempty" json:"interval,omitempty"`
+}
+
+//Merge 返回在当前选项之上再叠加 other 的结果，other 中设置的字段优先.
+func (o *Options) Merge(other *Options) *Options {
+	if o == nil {
+		return other
+	}
+	if other == nil {
+		return o
+	}
+	out := *o
+	if other.Include != "" {
+		out.Include = other.Include
+	}
+	if other.Exclude != "" {
+		out.Exclude = other.Exclude
+	}
+	if len(other.Rename) > 0 {
+		out.Rename = other.Rename
+	}
+	if other.Prefix != "" {
+		out.Prefix = other.Prefix
+	}
+	if other.Emoji != nil {
+		out.Emoji = other.Emoji
+	}
+	if other.Sort != "" {
+		out.Sort = other.Sort
+	}
+	if len(other.RegionOrder) > 0 {
+		out.RegionOrder = other.RegionOrder
+	}
+	if len(other.RegionGroups) > 0 {
+		out.RegionGroups = other.RegionGroups
+	}
+	if len(other.Groups) > 0 {
+		out.Groups = other.Groups
+	}
+	if other.InlineRuleSets != nil {
+		out.InlineRuleSets = other.InlineRuleSets
+	}
+	if other.CollapseRules != nil {
+		out.CollapseRules = other.CollapseRules
+	}
+	if other.SourceGroups != nil {
+		out.SourceGroups = other.SourceGroups
+	}
+	if other.InfoNodes != nil {
+		out.InfoNodes = other.InfoNodes
+	}
+	if len(other.Chains) > 0 {
+		out.Chains = other.Chains
+	}
+	return &out
+}
+
+//Validate 检查选项的取值是否合法.
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if _, err := regexp.Compile(o.Include); err != nil {
+		return fmt.Errorf("invalid include pattern -> %s", err)
+	}
+	if _, err := regexp.Compile(o.Exclude); err != nil {
+		return fmt.Errorf("invalid exclude pattern -> %s", err)
+	}
+	switch o.Sort {
+	case "", SortSource, SortName, SortRegion, SortMultiplier:
+	default:
+		return fmt.Errorf("invalid sort -> %s", o.Sort)
+	}
+	for _, r := range o.Rename {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid rename pattern -> %s", err)
+		}
+	}
+	for _, g := range o.RegionGroups {
+		if g.Name == "" {
+			return fmt.Errorf("region group name is empty -> %s", g.Pattern)
+		}
+		if _, err := regexp.Compile(g.Pattern); err != nil {
+			return fmt.Errorf("invalid region group pattern -> %s %s", g.Name, err)
+		}
+		switch g.Type {
+		case "", GroupSelect, GroupURLTest, GroupFallback, GroupLoadBalance:
+		default:
+			return fmt.Errorf("invalid region group type -> %s %s", g.Name, g.Type)
+		}
+	}
+	for _, g := range o.Groups {
+		if err := g.validate(); err != nil {
+			return err
+		}
+	}
+	for _, c := range o.Chains {
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Build 基于模板生成包含指定节点的配置.
+// 字段不完整的节点会被移除并记录在 Warnings 中，其余节点先按 Include 和 Exclude 过滤、重命名（手动添加的节点除外），
+// 再去重并排序，除 relay 外，模板中和选项中的每个分组都会加入全部节点；设置了地区分组时，
+// 地区分组会加入主分组（第一个 select 分组），主分组中只保留未被任何地区分组匹配的节点；开启来源分组时，
+// 每个订阅来源的分组也会加入主分组. 最后按代理链设置为匹配的节点设置 dialer-proxy 或生成 relay 分组.
+func Build(tpl *Template, proxies []*Proxy, opts *Options) (*Config, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	proxies, invalid := dropInvalidProxies(proxies)
+	//手动添加的节点不参与过滤和重命名
+	var manual []*Proxy
+	subscribed := make([]*Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		if p.manual {
+			manual = append(manual, p)
+		} else {
+			subscribed = append(subscribed, p)
+		}
+	}
+	proxies = filterProxies(subscribed, opts.Include, opts.Exclude)
+	proxies = renameProxies(proxies, opts)
+	proxies = append(proxies, manual...)
+
+	config := tpl.Config()
+	for _, g := range opts.Groups {
+		config.setGroup(g.clone())
+	}
+
+	//节点名称不能与模板中的节点以及分组重名
+	reserved := make(map[string]bool)
+	for _, p := range config.Proxy {
+		reserved[p.Name] = true
+	}
+	for _, g := range config.ProxyGroup {
+		reserved[g.Name] = true
+	}
+	for _, g := range opts.RegionGroups {
+		reserved[g.Name] = true
+	}
+	sourceGroups := opts.SourceGroups != nil && *opts.SourceGroups
+	if sourceGroups {
+		for _, p := range proxies {
+			if p.source != "" {
+				reserved[p.source] = true
+			}
+		}
+	}
+	for name := range builtinPolicies {
+		reserved[name] = true
+	}
+	proxies, config.Warnings = dedupProxies(proxies, reserved)
+	config.Warnings = append(invalid, config.Warnings...)
+	proxies = sortProxies(proxies, opts.Sort, opts.RegionOrder)
+	config.Proxy = append(config.Proxy, proxies...)
+
+	regionGroups, ungrouped := buildRegionGroups(proxies, opts.RegionGroups)
+	var sources []*ProxyGroup
+	if sourceGroups {
+		sources = buildSourceGroups(proxies)
+	}
+
+	var main *ProxyGroup
+	for _, g := range config.ProxyGroup {
+		if g.Type == GroupSelect {
+			main = g
+			break
+		}
+	}
+	for _, g := range config.ProxyGroup {
+		g.setDefaults()
+		if !g.autoFill() {
+			continue
+		}
+		if g == main {
+			for _, sg := range sources {
+				g.addProxy(sg.Name)
+			}
+		}
+		if g == main && len(regionGroups) > 0 {
+			for _, rg := range regionGroups {
+				g.addProxy(rg.Name)
+			}
+			for _, p := range ungrouped {
+				g.addProxy(p.Name)
+			}
+			continue
+		}
+		for _, p := range proxies {
+			g.addProxy(p.Name)
+		}
+	}
+	config.ProxyGroup = append(config.ProxyGroup, sources...)
+	config.ProxyGroup = append(config.ProxyGroup, regionGroups...)
+	if err := config.applyChains(opts.Chains); err != nil {
+		return nil, err
+	}
+
+	if err := config.checkGroups(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+//filterProxies 按名称过滤节点，include 和 exclude 为空时不过滤.
+func filterProxies(proxies []*Proxy, include, exclude string) []*Proxy {
+	if include == "" && exclude == "" {
+		return proxies
+	}
+	var includeRe, excludeRe *regexp.Regexp
+	if include != "" {
+		includeRe = regexp.MustCompile(include)
+	}
+	if exclude != "" {
+		excludeRe = regexp.MustCompile(exclude)
+	}
+	filtered := make([]*Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		if includeRe != nil && !includeRe.MatchString(p.Name) {
+			continue
+		}
+		if excludeRe != nil && excludeRe.MatchString(p.Name) {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+//setGroup 增加一个分组，已存在同名分组时替换该分组.
+func (m *Config) setGroup(group *ProxyGroup) {
+	for i, g := range m.ProxyGroup {
+		if g.Name == group.Name {
+			m.ProxyGroup[i] = group
+			return
+		}
+	}
+	m.ProxyGroup = append(m.ProxyGroup, group)
+}
+
+//buildRegionGroups 按规则生成地区分组，返回生成的分组以及没有匹配任何分组的节点.
+// 没有匹配到任何节点的分组会被忽略.
+func buildRegionGroups(proxies []*Proxy, rules []*RegionGroup) ([]*ProxyGroup, []*Proxy) {
+	if len(rules) == 0 {
+		return nil, proxies
+	}
+	matched := make(map[*Proxy]bool, len(proxies))
+	groups := make([]*ProxyGroup, 0, len(rules))
+	for _, rule := range rules {
+		re := regexp.MustCompile(rule.Pattern)
+		group := &ProxyGroup{
+			Name:    rule.Name,
+			Type:    rule.Type,
+			Proxies: make([]string, 0),
+		}
+		if group.Type == "" {
+			group.Type = GroupSelect
+		}
+		if group.Type != GroupSelect {
+			group.Url = rule.Url
+			group.Interval = rule.Interval
+			group.setDefaults()
+		}
+		for _, p := range proxies {
+			if re.MatchString(p.Name) {
+				group.addProxy(p.Name)
+				matched[p] = true
+			}
+		}
+		if len(group.Proxies) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	var ungrouped []*Proxy
+	for _, p := range proxies {
+		if !matched[p] {
+			ungrouped = append(ungrouped, p)
+		}
+	}
+	return groups, ungrouped
+}
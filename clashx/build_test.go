@@ -0,0 +1,73 @@
+package clashx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectRegion(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "香港 01", want: "HK"},
+		{name: "HK-02", want: "HK"},
+		{name: "🇯🇵 01", want: "JP"},
+		{name: "UK 01", want: "GB"},
+		{name: "GB|02", want: "GB"},
+		{name: "英国 伦敦", want: "GB"},
+		{name: "United Kingdom", want: "GB"},
+		{name: "Los Angeles 01", want: "US"},
+		{name: "UKRAINE 01"},
+		{name: "USA 01"},
+		{name: "HKBN"},
+		{name: "Node 01"},
+	}
+	for _, tt := range tests {
+		var got string
+		if region := DetectRegion(tt.name); region != nil {
+			got = region.Code
+		}
+		if got != tt.want {
+			t.Errorf("DetectRegion(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildRegionGroups(t *testing.T) {
+	var proxies []*Proxy
+	for i, name := range []string{"香港 01", "UK 01", "Node 01", "英国 02", "香港 02 | 日本", "Node 02"} {
+		proxies = append(proxies, testProxy(name, 8388+i))
+	}
+
+	groups, ungrouped := buildRegionGroups(proxies, DefaultRegionGroups())
+	want := []*ProxyGroup{
+		{Name: "🇭🇰 香港", Type: GroupURLTest, Proxies: []string{"香港 01", "香港 02 | 日本"}, Url: defaultTestUrl, Interval: defaultTestInterval},
+		{Name: "🇯🇵 日本", Type: GroupURLTest, Proxies: []string{"香港 02 | 日本"}, Url: defaultTestUrl, Interval: defaultTestInterval},
+		{Name: "🇬🇧 英国", Type: GroupURLTest, Proxies: []string{"UK 01", "英国 02"}, Url: defaultTestUrl, Interval: defaultTestInterval},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("groups = %+v, want %+v", groups, want)
+	}
+	if len(ungrouped) != 2 || ungrouped[0] != proxies[2] || ungrouped[1] != proxies[5] {
+		t.Errorf("ungrouped = %+v, want Node 01 and Node 02", ungrouped)
+	}
+
+	groups, _ = buildRegionGroups(proxies, []*RegionGroup{
+		{Name: "HK", Pattern: "香港"},
+		{Name: "Fallback", Pattern: "^Node", Type: GroupFallback, Url: "http://example.com/204", Interval: 60},
+		{Name: "Empty", Pattern: "美国"},
+	})
+	want = []*ProxyGroup{
+		{Name: "HK", Type: GroupSelect, Proxies: []string{"香港 01", "香港 02 | 日本"}},
+		{Name: "Fallback", Type: GroupFallback, Proxies: []string{"Node 01", "Node 02"}, Url: "http://example.com/204", Interval: 60},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("groups = %+v, want %+v", groups, want)
+	}
+
+	groups, ungrouped = buildRegionGroups(proxies, nil)
+	if groups != nil || len(ungrouped) != len(proxies) {
+		t.Errorf("buildRegionGroups() without rules = %+v, %d nodes", groups, len(ungrouped))
+	}
+}
@@ -0,0 +1,170 @@
+package clashx
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// 代理链模式
+const (
+	//在匹配的节点上设置 dialer-proxy，需要 Clash Meta 支持
+	ChainDialerProxy = "dialer-proxy"
+	//为每个匹配的节点生成一个由前置代理和该节点组成的 relay 分组
+	ChainRelay = "relay"
+)
+
+//ProxyChain 代理链设置，名称匹配 Pattern 的节点经由 Via 连接.
+type ProxyChain struct {
+	//需要经由前置代理连接的节点名称正则
+	Pattern string `yaml:"pattern" json:"pattern"`
+	//前置代理，可以是节点或分组名称
+	Via string `yaml:"via" json:"via"`
+	//代理链模式：dialer-proxy / relay，默认为 dialer-proxy
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+}
+
+func (m *ProxyChain) validate() error {
+	if m.Via == "" {
+		return fmt.Errorf("proxy chain via is empty -> %s", m.Pattern)
+	}
+	if builtinPolicies[m.Via] {
+		return fmt.Errorf("proxy chain cannot go via builtin policy -> %s", m.Via)
+	}
+	if _, err := regexp.Compile(m.Pattern); err != nil {
+		return fmt.Errorf("invalid proxy chain pattern -> %s %s", m.Via, err)
+	}
+	switch m.Mode {
+	case "", ChainDialerProxy, ChainRelay:
+	default:
+		return fmt.Errorf("invalid proxy chain mode -> %s", m.Mode)
+	}
+	return nil
+}
+
+//applyChains 按代理链设置处理匹配的节点，前置代理本身不会被处理，已设置 dialer-proxy 的节点只使用第一个匹配的设置.
+//relay 模式下，分组中的节点会被替换为对应的 relay 分组；前置代理为分组时，匹配的节点会从该分组以及它可以到达的全部分组中移除，
+// 以免产生循环引用，因此变为空的分组也会被移除.
+func (m *Config) applyChains(chains []*ProxyChain) error {
+	for _, chain := range chains {
+		if !m.hasPolicy(chain.Via) {
+			return fmt.Errorf("proxy chain references unknown proxy or group -> %s", chain.Via)
+		}
+		reachable := m.reachableGroups(chain.Via)
+		re := regexp.MustCompile(chain.Pattern)
+
+		//relay 分组名称不能与已有的节点和分组重名
+		used := make(map[string]bool, len(m.Proxy)+len(m.ProxyGroup))
+		for _, p := range m.Proxy {
+			used[p.Name] = true
+		}
+		for _, g := range m.ProxyGroup {
+			used[g.Name] = true
+		}
+		replaced := make(map[string]string)
+		var relays []*ProxyGroup
+		for i, p := range m.Proxy {
+			if p.Name == chain.Via || !re.MatchString(p.Name) {
+				continue
+			}
+			if chain.Mode == ChainRelay {
+				name := p.Name + " via " + chain.Via
+				if used[name] {
+					name = uniqueName(name, used)
+				}
+				used[name] = true
+				replaced[p.Name] = name
+				relays = append(relays, &ProxyGroup{Name: name, Type: GroupRelay, Proxies: []string{chain.Via, p.Name}})
+				continue
+			}
+			if p.DialerProxy != "" {
+				continue
+			}
+			proxy := *p
+			proxy.DialerProxy = chain.Via
+			m.Proxy[i] = &proxy
+			replaced[p.Name] = ""
+		}
+		if len(replaced) == 0 {
+			continue
+		}
+		emptied := make(map[string]bool)
+		for _, g := range m.ProxyGroup {
+			if g.Type == GroupRelay || (!reachable[g.Name] && chain.Mode != ChainRelay) {
+				continue
+			}
+			proxies := make([]string, 0, len(g.Proxies))
+			for _, name := range g.Proxies {
+				relay, ok := replaced[name]
+				switch {
+				case !ok:
+					proxies = append(proxies, name)
+				case !reachable[g.Name] && relay != "":
+					proxies = append(proxies, relay)
+				}
+			}
+			if len(proxies) == 0 && len(g.Proxies) > 0 {
+				emptied[g.Name] = true
+			}
+			g.Proxies = proxies
+		}
+		m.ProxyGroup = append(m.ProxyGroup, relays...)
+		if err := m.removeEmptyGroups(chain.Via, emptied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//reachableGroups 返回从指定分组出发经由分组成员可以到达的全部分组名称，包括该分组本身，name 不是分组时返回空.
+func (m *Config) reachableGroups(name string) map[string]bool {
+	groups := make(map[string]*ProxyGroup, len(m.ProxyGroup))
+	for _, g := range m.ProxyGroup {
+		groups[g.Name] = g
+	}
+	reachable := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		g, ok := groups[name]
+		if !ok || reachable[name] {
+			return
+		}
+		reachable[name] = true
+		for _, member := range g.Proxies {
+			visit(member)
+		}
+	}
+	visit(name)
+	return reachable
+}
+
+//removeEmptyGroups 移除因成员被移出而变为空的分组以及其他分组对它们的引用，引用被移除后变为空的分组同样会被移除，
+// 前置代理分组变为空时返回错误.
+func (m *Config) removeEmptyGroups(via string, emptied map[string]bool) error {
+	for len(emptied) > 0 {
+		if emptied[via] {
+			return fmt.Errorf("proxy chain via group has no members left -> %s", via)
+		}
+		groups := make([]*ProxyGroup, 0, len(m.ProxyGroup))
+		next := make(map[string]bool)
+		for _, g := range m.ProxyGroup {
+			if emptied[g.Name] {
+				m.Warnings = append(m.Warnings, fmt.Sprintf("proxy group removed, all of its members go via %s -> %s", via, g.Name))
+				continue
+			}
+			proxies := make([]string, 0, len(g.Proxies))
+			for _, name := range g.Proxies {
+				if !emptied[name] {
+					proxies = append(proxies, name)
+				}
+			}
+			if len(proxies) == 0 && len(g.Proxies) > 0 {
+				next[g.Name] = true
+			}
+			g.Proxies = proxies
+			groups = append(groups, g)
+		}
+		m.ProxyGroup = groups
+		emptied = next
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package clashx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyChains(t *testing.T) {
+	config := func() *Config {
+		return &Config{
+			Proxy: []*Proxy{testProxy("香港 01", 8388), testProxy("香港 02", 8389), testProxy("日本 01", 8390), testProxy("落地", 8391)},
+			ProxyGroup: []*ProxyGroup{
+				{Name: "Proxy", Type: GroupSelect, Proxies: []string{"Auto", "香港", "日本", "落地"}},
+				{Name: "Auto", Type: GroupURLTest, Proxies: []string{"香港 01", "香港 02", "日本 01", "落地"}},
+				{Name: "香港", Type: GroupSelect, Proxies: []string{"香港 01", "香港 02"}},
+				{Name: "日本", Type: GroupSelect, Proxies: []string{"日本 01"}},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		chains   []*ProxyChain
+		groups   map[string][]string
+		dialers  map[string]string
+		warnings []string
+		err      string
+	}{
+		{
+			name:   "dialer-proxy via main group",
+			chains: []*ProxyChain{{Pattern: "^落地", Via: "Proxy"}},
+			groups: map[string][]string{
+				"Proxy": {"Auto", "香港", "日本"},
+				"Auto":  {"香港 01", "香港 02", "日本 01"},
+				"香港":    {"香港 01", "香港 02"},
+				"日本":    {"日本 01"},
+			},
+			dialers: map[string]string{"落地": "Proxy"},
+		},
+		{
+			name:   "emptied region group is removed",
+			chains: []*ProxyChain{{Pattern: "^日本", Via: "Proxy"}},
+			groups: map[string][]string{
+				"Proxy": {"Auto", "香港", "落地"},
+				"Auto":  {"香港 01", "香港 02", "落地"},
+				"香港":    {"香港 01", "香港 02"},
+			},
+			dialers:  map[string]string{"日本 01": "Proxy"},
+			warnings: []string{"proxy group removed, all of its members go via Proxy -> 日本"},
+		},
+		{
+			name:   "first matching chain wins",
+			chains: []*ProxyChain{{Pattern: "^落地", Via: "香港"}, {Pattern: "^落地", Via: "日本"}},
+			groups: map[string][]string{
+				"Proxy": {"Auto", "香港", "日本", "落地"},
+				"Auto":  {"香港 01", "香港 02", "日本 01", "落地"},
+				"香港":    {"香港 01", "香港 02"},
+				"日本":    {"日本 01"},
+			},
+			dialers: map[string]string{"落地": "香港"},
+		},
+		{
+			name:   "relay via region group",
+			chains: []*ProxyChain{{Pattern: "^落地", Via: "香港", Mode: ChainRelay}},
+			groups: map[string][]string{
+				"Proxy":     {"Auto", "香港", "日本", "落地 via 香港"},
+				"Auto":      {"香港 01", "香港 02", "日本 01", "落地 via 香港"},
+				"香港":        {"香港 01", "香港 02"},
+				"日本":        {"日本 01"},
+				"落地 via 香港": {"香港", "落地"},
+			},
+		},
+		{
+			name:   "relay via main group",
+			chains: []*ProxyChain{{Pattern: "^落地", Via: "Proxy", Mode: ChainRelay}},
+			groups: map[string][]string{
+				"Proxy":        {"Auto", "香港", "日本"},
+				"Auto":         {"香港 01", "香港 02", "日本 01"},
+				"香港":           {"香港 01", "香港 02"},
+				"日本":           {"日本 01"},
+				"落地 via Proxy": {"Proxy", "落地"},
+			},
+		},
+		{
+			name:   "emptied via group",
+			chains: []*ProxyChain{{Pattern: "^日本", Via: "日本"}},
+			err:    "proxy chain via group has no members left -> 日本",
+		},
+		{
+			name:   "every node via main group",
+			chains: []*ProxyChain{{Pattern: ".", Via: "Auto"}},
+			err:    "proxy chain via group has no members left -> Auto",
+		},
+		{
+			name:   "unknown via",
+			chains: []*ProxyChain{{Pattern: ".", Via: "美国"}},
+			err:    "proxy chain references unknown proxy or group -> 美国",
+		},
+	}
+	for _, tt := range tests {
+		c := config()
+		err := c.applyChains(tt.chains)
+		if err != nil || tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err := c.checkGroups(); err != nil {
+			t.Errorf("%s: checkGroups() error = %v", tt.name, err)
+		}
+		groups := make(map[string][]string, len(c.ProxyGroup))
+		for _, g := range c.ProxyGroup {
+			groups[g.Name] = g.Proxies
+		}
+		if !reflect.DeepEqual(groups, tt.groups) {
+			t.Errorf("%s: groups = %q, want %q", tt.name, groups, tt.groups)
+		}
+		dialers := make(map[string]string)
+		for _, p := range c.Proxy {
+			if p.DialerProxy != "" {
+				dialers[p.Name] = p.DialerProxy
+			}
+		}
+		if len(dialers) == 0 {
+			dialers = nil
+		}
+		if !reflect.DeepEqual(dialers, tt.dialers) {
+			t.Errorf("%s: dialers = %q, want %q", tt.name, dialers, tt.dialers)
+		}
+		if !reflect.DeepEqual(c.Warnings, tt.warnings) {
+			t.Errorf("%s: warnings = %q, want %q", tt.name, c.Warnings, tt.warnings)
+		}
+	}
+}
+
+func TestApplyChainsKeepsTemplateProxies(t *testing.T) {
+	c := &Config{
+		Proxy:      []*Proxy{testProxy("香港 01", 8388), testProxy("落地", 8389)},
+		ProxyGroup: []*ProxyGroup{{Name: "Proxy", Type: GroupSelect, Proxies: []string{"香港 01", "落地"}}},
+	}
+	original := c.Proxy[1]
+	if err := c.applyChains([]*ProxyChain{{Pattern: "^落地", Via: "香港 01"}}); err != nil {
+		t.Fatal(err)
+	}
+	if original.DialerProxy != "" {
+		t.Errorf("applyChains() modifies the original proxy")
+	}
+	if c.Proxy[1].DialerProxy != "香港 01" {
+		t.Errorf("dialer-proxy = %q, want %q", c.Proxy[1].DialerProxy, "香港 01")
+	}
+	//前置代理为节点时不会从分组中移除匹配的节点
+	if want := []string{"香港 01", "落地"}; !reflect.DeepEqual(c.ProxyGroup[0].Proxies, want) {
+		t.Errorf("groups = %q, want %q", c.ProxyGroup[0].Proxies, want)
+	}
+	if err := c.checkGroups(); err != nil {
+		t.Errorf("checkGroups() error = %v", err)
+	}
+}
+
+func TestApplyChainsCycle(t *testing.T) {
+	c := &Config{
+		Proxy: []*Proxy{testProxy("香港 01", 8388), testProxy("落地", 8389)},
+		ProxyGroup: []*ProxyGroup{
+			{Name: "Proxy", Type: GroupSelect, Proxies: []string{"香港", "落地"}},
+			{Name: "香港", Type: GroupSelect, Proxies: []string{"香港 01"}},
+		},
+	}
+	//模板中已有的 dialer-proxy 与代理链一起形成循环
+	c.Proxy[0].DialerProxy = "落地"
+	if err := c.applyChains([]*ProxyChain{{Pattern: "^落地", Via: "香港"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "proxy group reference cycle -> Proxy -> 香港 -> 香港 01 -> 落地 -> 香港"
+	if err := c.checkGroups(); err == nil || err.Error() != want {
+		t.Errorf("checkGroups() error = %v, want %q", err, want)
+	}
+}
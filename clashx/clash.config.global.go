@@ -0,0 +1,49 @@
+package clashx
+
+const GlobalConfigStr = `#---------------------------------------------------#
+## 全局代理配置：除局域网外的所有流量都走代理
+#---------------------------------------------------#
+
+# HTTP 代理端口
+port: 7890
+
+# SOCKS5 代理端口
+socks-port: 7891
+
+# 允许局域网的连接（可用来共享代理）
+allow-lan: false
+
+# 规则模式：Rule（规则） / Global（全局代理）/ Direct（全局直连）
+mode: Global
+
+# 设置日志输出级别 (默认级别：silent，即不输出任何内容，以避免因日志内容过大而导致程序内存溢出）。
+log-level: silent
+
+# clash 的 RESTful API
+external-controller: 127.0.0.1:9090
+
+dns:
+  enable: true
+  ipv6: false
+  nameserver:
+    - 223.5.5.5
+    - 114.114.114.114
+  fallback:
+    - tls://1.0.0.1:853
+    - tls://dns.google:853
+
+Proxy:
+
+Proxy Group:
+
+Rule:
+# LAN
+  - DOMAIN-SUFFIX,local,DIRECT
+  - IP-CIDR,127.0.0.0/8,DIRECT
+  - IP-CIDR,172.16.0.0/12,DIRECT
+  - IP-CIDR,192.168.0.0/16,DIRECT
+  - IP-CIDR,10.0.0.0/8,DIRECT
+  - IP-CIDR,100.64.0.0/10,DIRECT
+
+# 最终规则
+  - MATCH,Proxy`
@@ -107,6 +107,17 @@ dns:
 #   若要了解更多关于 DoH/DoT 相关技术，请自行查阅规范文档。
 
 
+# 如需更完整的广告屏蔽，可以添加由本服务转换的 AdGuard DNS 过滤列表，并在规则中引用 RULE-SET,adblock,REJECT.
+# 该列表包含数万条规则且需要访问 adguardteam.github.io，默认不启用.
+# rule-providers:
+#   adblock:
+#     type: http
+#     behavior: classical
+#     format: adblock
+#     url: https://adguardteam.github.io/AdGuardSDNSFilter/Filters/filter.txt
+#     path: ./ruleset/adblock.yaml
+#     interval: 86400
+
 Proxy:
 
 Proxy Group:
@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"gopkg.in/yaml.v3"
-	"io"
 	"log"
 	"strings"
 	"sync"
@@ -17,8 +16,9 @@ var (
 	lock        = &sync.RWMutex{}
 )
 
+//Converter 将订阅内容解析为节点列表.
 type Converter interface {
-	Convert(body string) (*Config, error)
+	Convert(body string) ([]*Proxy, error)
 }
 
 type Config struct {
@@ -41,30 +41,60 @@ type Config struct {
 	// 参数应填写配置目录的相对路径或绝对路径。
 	ExternalUi string `yaml:"external-ui"`
 	//RESTful API 的口令 (可选)
-	Secret     string        `yaml:"secret"`
+	Secret string `yaml:"secret"`
+	//本地 SOCKS5 / HTTP(S) 服务认证，格式为 user:pass
+	Authentication []string `yaml:"authentication,omitempty"`
+	//静态 hosts，支持通配符
+	Hosts map[string]string `yaml:"hosts,omitempty"`
+	DNS   *DNS              `yaml:"dns,omitempty"`
+	Tun   *Tun              `yaml:"tun,omitempty"`
+
 	Proxy      []*Proxy      `yaml:"Proxy"`
 	ProxyGroup []*ProxyGroup `yaml:"Proxy Group"`
-	Rule       []string      `yaml:"Rule"`
+	//规则集，可以在规则中通过 RULE-SET,名称,策略 引用
+	RuleProviders map[string]*RuleProvider `yaml:"rule-providers,omitempty"`
+	Rule          []string                 `yaml:"Rule"`
+
+	//生成配置过程中产生的警告，不会输出到配置文件中
+	Warnings []string `yaml:"-"`
+
+	//配置所基于的模板节点树
+	node *yaml.Node
+	//模板中使用新版字段名的字段，键为本程序使用的字段名
+	keys map[string]string
 }
 
-func (m *Config) AddProxy(p *Proxy) {
-	for index, proxy := range m.Proxy {
-		if proxy.Name == p.Name {
-			m.Proxy[index] = p
-			return
+//Clone 深拷贝一个配置.
+func (m *Config) Clone() *Config {
+	c := *m
+	c.Proxy = make([]*Proxy, len(m.Proxy))
+	for i, p := range m.Proxy {
+		proxy := *p
+		c.Proxy[i] = &proxy
+	}
+	c.ProxyGroup = make([]*ProxyGroup, len(m.ProxyGroup))
+	for i, g := range m.ProxyGroup {
+		c.ProxyGroup[i] = g.clone()
+	}
+	c.Rule = append([]string(nil), m.Rule...)
+	if m.RuleProviders != nil {
+		c.RuleProviders = make(map[string]*RuleProvider, len(m.RuleProviders))
+		for name, p := range m.RuleProviders {
+			provider := *p
+			c.RuleProviders[name] = &provider
 		}
 	}
-	m.Proxy = append(m.Proxy, p)
-	for _, group := range m.ProxyGroup {
-		if group.Type == "select" {
-			for _, name := range group.Proxies {
-				if name == p.Name {
-					return
-				}
-			}
-			group.Proxies = append(group.Proxies, p.Name)
+	c.Warnings = append([]string(nil), m.Warnings...)
+	c.Authentication = append([]string(nil), m.Authentication...)
+	if m.Hosts != nil {
+		c.Hosts = make(map[string]string, len(m.Hosts))
+		for k, v := range m.Hosts {
+			c.Hosts[k] = v
 		}
 	}
+	c.DNS = m.DNS.clone()
+	c.Tun = m.Tun.clone()
+	return &c
 }
 
 func (m *Config) String() string {
@@ -72,7 +102,7 @@ func (m *Config) String() string {
 		return ""
 	}
 
-	b, err := yaml.Marshal(m)
+	b, err := m.marshal()
 	if err != nil {
 		log.Println(err)
 		return ""
@@ -98,6 +128,15 @@ type Proxy struct {
 	Plugin         string            `yaml:"plugin"`
 	PluginOpts     map[string]string `yaml:"plugin-opts"`
 	Network        string            `yaml:"network"`
+	//经由该节点或分组连接，需要 Clash Meta 支持
+	DialerProxy string `yaml:"dialer-proxy,omitempty"`
+	//其他协议特有的字段，如 socks5 的 username、trojan 的 sni，原样输出
+	Extra map[string]interface{} `yaml:",inline"`
+
+	//节点所属的订阅来源
+	source string
+	//手动添加的节点，不参与过滤和重命名
+	manual bool
 }
 
 func (m *Proxy) String() string {
@@ -111,96 +150,65 @@ func (m *Proxy) String() string {
 }
 
 type ProxyGroup struct {
-	Name     string   `yaml:"name"`
-	Type     string   `yaml:"type"`
-	Proxies  []string `yaml:"proxies"`
-	Url      string   `yaml:"url"`
-	Interval int      `yaml:"interval"`
+	Name string `yaml:"name" json:"name"`
+	//分组类型：select / url-test / fallback / load-balance / relay
+	Type    string   `yaml:"type" json:"type"`
+	Proxies []string `yaml:"proxies" json:"proxies,omitempty"`
+	//url-test / fallback / load-balance 用于测速的地址和间隔（秒）
+	Url      string `yaml:"url,omitempty" json:"url,omitempty"`
+	Interval int    `yaml:"interval,omitempty" json:"interval,omitempty"`
+	//url-test 切换节点的延迟容差（毫秒）
+	Tolerance int `yaml:"tolerance,omitempty" json:"tolerance,omitempty"`
+	//url-test 只在分组被使用时测速
+	Lazy *bool `yaml:"lazy,omitempty" json:"lazy,omitempty"`
+	//load-balance 负载均衡策略：consistent-hashing / round-robin
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
 }
 
 type VmessClashX struct {
-	c *Config
 }
 
-func NewVmessClashX(config io.Reader) *VmessClashX {
-	c := &Config{}
-	if err := yaml.NewDecoder(config).Decode(c); err != nil {
-		log.Panicln(err)
-	}
-	if len(c.ProxyGroup) == 0 {
-		c.ProxyGroup = make([]*ProxyGroup, 1)
-		c.ProxyGroup[0] = &ProxyGroup{
-			Name:     "Proxy",
-			Type:     "select",
-			Proxies:  make([]string, 0),
-			Url:      "",
-			Interval: 0,
-		}
-	}
-	return &VmessClashX{c: c}
+func NewVmessClashX() *VmessClashX {
+	return &VmessClashX{}
 }
 
-func (m *VmessClashX) Convert(body string) (*Config, error) {
+func (m *VmessClashX) Convert(body string) ([]*Proxy, error) {
 	body = strings.ReplaceAll(body, " ", "")
 	b, err := base64.RawURLEncoding.DecodeString(body)
 	if err != nil {
-		return nil, err
+		//本地维护的节点列表可以不经过 base64 编码，每行一个链接
+		if !strings.Contains(body, string(VmessPrefix)) {
+			return nil, err
+		}
+		b = []byte(body)
 	}
 
-	config := *m.c
-
+	var proxies []*Proxy
 	for _, bb := range bytes.Split(b, []byte("\n")) {
-		proxy := &Proxy{}
+		bb = bytes.TrimSpace(bb)
 		if bytes.HasPrefix(bb, VmessPrefix) {
-			proxy.Type = "vmess"
-			bbb, err := base64.StdEncoding.DecodeString(string(bytes.TrimPrefix(bb, VmessPrefix)))
+			proxy, err := parseVmess(string(bb))
 			if err != nil {
 				return nil, err
 			}
-			data := V2rayConfig{}
-
-			if err := json.Unmarshal(bbb, &data); err != nil {
-				log.Println(err)
-				return nil, err
-			}
-			proxy.Name = data.Ps
-			proxy.Server = data.Add
-			if port, err := data.Port.Int64(); err == nil {
-				proxy.Port = int(port)
-			}
-			proxy.UUID = data.Id
-			if aid, err := data.Aid.Int64(); err == nil {
-				proxy.AlterId = int(aid)
-			}
-			proxy.Cipher = data.Type
-			proxy.TLS = data.TLS == "tls"
-			if data.Net == "ws" {
-				proxy.Network = data.Net
-				proxy.WSPath = data.Path
-				proxy.WSHeaders = map[string]string{"Host": data.Host}
-			}
-
-			config.Proxy = append(config.Proxy, proxy)
-			for _, g := range config.ProxyGroup {
-				isOk := true
-				for _, p := range g.Proxies {
-					if p == proxy.Name {
-						isOk = false
-						break
-					}
-				}
-				if isOk {
-					g.Proxies = append(g.Proxies, proxy.Name)
-				}
-			}
+			proxies = append(proxies, proxy)
 		}
 	}
 
-	return &config, nil
+	return proxies, nil
 }
 
 func SingleVmessConvert(body string) (*Config, error) {
-	bbb, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(body, string(VmessPrefix)))
+	proxy, err := parseVmess(body)
+	if err != nil {
+		return nil, err
+	}
+	return Build(DefaultTemplate, []*Proxy{proxy}, nil)
+}
+
+//parseVmess 解析一个 vmess:// 链接.
+func parseVmess(link string) (*Proxy, error) {
+	bbb, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(link, string(VmessPrefix)))
 	if err != nil {
 		return nil, err
 	}
@@ -221,31 +229,17 @@ func SingleVmessConvert(body string) (*Config, error) {
 	if aid, err := data.Aid.Int64(); err == nil {
 		proxy.AlterId = int(aid)
 	}
-	proxy.Cipher = data.Type
+	proxy.Cipher = data.Scy
+	if proxy.Cipher == "" {
+		proxy.Cipher = "auto"
+	}
 	proxy.TLS = data.TLS == "tls"
 	if data.Net == "ws" {
 		proxy.Network = data.Net
 		proxy.WSPath = data.Path
 		proxy.WSHeaders = map[string]string{"Host": data.Host}
 	}
-
-	c := &Config{}
-	if err := yaml.NewDecoder(strings.NewReader(ConfigStr)).Decode(c); err != nil {
-		return nil, err
-	}
-	if len(c.ProxyGroup) == 0 {
-		c.ProxyGroup = make([]*ProxyGroup, 1)
-		c.ProxyGroup[0] = &ProxyGroup{
-			Name:     "Proxy",
-			Type:     "select",
-			Proxies:  make([]string, 0),
-			Url:      "",
-			Interval: 0,
-		}
-	}
-	c.AddProxy(proxy)
-
-	return c, nil
+	return proxy, nil
 }
 
 func Register(name string, c Converter) {
@@ -264,5 +258,5 @@ func GetConverter(name string) Converter {
 }
 
 func init() {
-	Register("vmess", NewVmessClashX(strings.NewReader(ConfigStr)))
+	Register("vmess", NewVmessClashX())
 }
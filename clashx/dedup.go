@@ -0,0 +1,50 @@
+package clashx
+
+import (
+	"fmt"
+	"strconv"
+)
+
+//identity 节点的唯一标识，由协议、服务器、端口以及认证信息组成.
+func (m *Proxy) identity() string {
+	return m.Type + "|" + m.Server + "|" + strconv.Itoa(m.Port) + "|" + m.UUID + "|" + m.Password + "|" + m.Cipher
+}
+
+//dedupProxies 移除重复的节点，并为名称相同的不同节点增加 " 2"、" 3" 等后缀.
+//reserved 为已被占用的名称，返回处理后的节点以及处理过程中产生的警告.
+func dedupProxies(proxies []*Proxy, reserved map[string]bool) ([]*Proxy, []string) {
+	var warnings []string
+	used := make(map[string]bool, len(reserved)+len(proxies))
+	for name := range reserved {
+		used[name] = true
+	}
+	seen := make(map[string]string, len(proxies))
+	result := make([]*Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		id := p.identity()
+		if name, ok := seen[id]; ok {
+			warnings = append(warnings, fmt.Sprintf("duplicate node removed -> %s (same as %s)", p.Name, name))
+			continue
+		}
+		if used[p.Name] {
+			name := uniqueName(p.Name, used)
+			warnings = append(warnings, fmt.Sprintf("duplicate node name renamed -> %s => %s", p.Name, name))
+			proxy := *p
+			proxy.Name = name
+			p = &proxy
+		}
+		used[p.Name] = true
+		seen[id] = p.Name
+		result = append(result, p)
+	}
+	return result, warnings
+}
+
+//uniqueName 为名称增加数字后缀，直到该名称未被占用.
+func uniqueName(name string, used map[string]bool) string {
+	for i := 2; ; i++ {
+		if n := name + " " + strconv.Itoa(i); !used[n] {
+			return n
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package clashx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupProxies(t *testing.T) {
+	tests := []struct {
+		name     string
+		proxies  []*Proxy
+		reserved map[string]bool
+		names    []string
+		warnings []string
+	}{
+		{
+			name:    "unique",
+			proxies: []*Proxy{testProxy("香港 01", 8388), testProxy("香港 02", 8389)},
+			names:   []string{"香港 01", "香港 02"},
+		},
+		{
+			name:     "same node",
+			proxies:  []*Proxy{testProxy("香港 01", 8388), testProxy("HK 01", 8388)},
+			names:    []string{"香港 01"},
+			warnings: []string{"duplicate node removed -> HK 01 (same as 香港 01)"},
+		},
+		{
+			name:     "same name",
+			proxies:  []*Proxy{testProxy("香港", 8388), testProxy("香港", 8389), testProxy("香港", 8390)},
+			names:    []string{"香港", "香港 2", "香港 3"},
+			warnings: []string{"duplicate node name renamed -> 香港 => 香港 2", "duplicate node name renamed -> 香港 => 香港 3"},
+		},
+		{
+			name:     "renamed node is compared by its new name",
+			proxies:  []*Proxy{testProxy("香港", 8388), testProxy("香港", 8389), testProxy("香港 2", 8390), testProxy("HK", 8389)},
+			names:    []string{"香港", "香港 2", "香港 2 2"},
+			warnings: []string{"duplicate node name renamed -> 香港 => 香港 2", "duplicate node name renamed -> 香港 2 => 香港 2 2", "duplicate node removed -> HK (same as 香港 2)"},
+		},
+		{
+			name:     "reserved name",
+			proxies:  []*Proxy{testProxy("Proxy", 8388), testProxy("DIRECT", 8389)},
+			reserved: map[string]bool{"Proxy": true, "DIRECT": true},
+			names:    []string{"Proxy 2", "DIRECT 2"},
+			warnings: []string{"duplicate node name renamed -> Proxy => Proxy 2", "duplicate node name renamed -> DIRECT => DIRECT 2"},
+		},
+		{
+			name:    "different credentials",
+			proxies: []*Proxy{testProxy("A", 8388), {Name: "B", Type: "ss", Server: "1.2.3.4", Port: 8388, Cipher: "aes-128-gcm", Password: "other"}},
+			names:   []string{"A", "B"},
+		},
+	}
+	for _, tt := range tests {
+		original := make([]string, len(tt.proxies))
+		for i, p := range tt.proxies {
+			original[i] = p.Name
+		}
+		proxies, warnings := dedupProxies(tt.proxies, tt.reserved)
+		var names []string
+		for _, p := range proxies {
+			names = append(names, p.Name)
+		}
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("%s: names = %q, want %q", tt.name, names, tt.names)
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%s: warnings = %q, want %q", tt.name, warnings, tt.warnings)
+		}
+		for i, p := range tt.proxies {
+			if p.Name != original[i] {
+				t.Errorf("%s: dedupProxies() renames the input node %q", tt.name, original[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package clashx
+
+import (
+	"fmt"
+	"strings"
+)
+
+//DNS clash 内置 DNS 服务配置.
+type DNS struct {
+	Enable bool `yaml:"enable" json:"enable"`
+	IPv6   bool `yaml:"ipv6" json:"ipv6"`
+	//DNS 服务监听地址
+	Listen string `yaml:"listen,omitempty" json:"listen,omitempty"`
+	//增强模式：fake-ip / redir-host
+	EnhancedMode string `yaml:"enhanced-mode,omitempty" json:"enhanced_mode,omitempty"`
+	FakeIPRange  string `yaml:"fake-ip-range,omitempty" json:"fake_ip_range,omitempty"`
+	//fake-ip 白名单列表
+	FakeIPFilter      []string        `yaml:"fake-ip-filter,omitempty" json:"fake_ip_filter,omitempty"`
+	DefaultNameserver []string        `yaml:"default-nameserver,omitempty" json:"default_nameserver,omitempty"`
+	Nameserver        []string        `yaml:"nameserver,omitempty" json:"nameserver,omitempty"`
+	Fallback          []string        `yaml:"fallback,omitempty" json:"fallback,omitempty"`
+	FallbackFilter    *FallbackFilter `yaml:"fallback-filter,omitempty" json:"fallback_filter,omitempty"`
+}
+
+//FallbackFilter 满足条件的解析结果会使用 fallback 列表内服务器的结果.
+type FallbackFilter struct {
+	GeoIP  bool     `yaml:"geoip" json:"geoip"`
+	IPCIDR []string `yaml:"ipcidr,omitempty" json:"ipcidr,omitempty"`
+	Domain []string `yaml:"domain,omitempty" json:"domain,omitempty"`
+}
+
+//Tun TUN 模式配置.
+type Tun struct {
+	Enable bool `yaml:"enable" json:"enable"`
+	//协议栈：system / gvisor
+	Stack               string   `yaml:"stack,omitempty" json:"stack,omitempty"`
+	DNSHijack           []string `yaml:"dns-hijack,omitempty" json:"dns_hijack,omitempty"`
+	AutoRoute           bool     `yaml:"auto-route,omitempty" json:"auto_route,omitempty"`
+	AutoDetectInterface bool     `yaml:"auto-detect-interface,omitempty" json:"auto_detect_interface,omitempty"`
+}
+
+func (m *DNS) clone() *DNS {
+	if m == nil {
+		return nil
+	}
+	dns := *m
+	dns.FakeIPFilter = append([]string(nil), m.FakeIPFilter...)
+	dns.DefaultNameserver = append([]string(nil), m.DefaultNameserver...)
+	dns.Nameserver = append([]string(nil), m.Nameserver...)
+	dns.Fallback = append([]string(nil), m.Fallback...)
+	if m.FallbackFilter != nil {
+		filter := *m.FallbackFilter
+		filter.IPCIDR = append([]string(nil), m.FallbackFilter.IPCIDR...)
+		filter.Domain = append([]string(nil), m.FallbackFilter.Domain...)
+		dns.FallbackFilter = &filter
+	}
+	return &dns
+}
+
+func (m *Tun) clone() *Tun {
+	if m == nil {
+		return nil
+	}
+	tun := *m
+	tun.DNSHijack = append([]string(nil), m.DNSHijack...)
+	return &tun
+}
+
+//Override 托管配置的覆盖项，未设置的字段保留模板中的值.
+type Override struct {
+	Port           int               `yaml:"port,omitempty" json:"port,omitempty"`
+	SocksPort      int               `yaml:"socks-port,omitempty" json:"socks_port,omitempty"`
+	AllowLan       *bool             `yaml:"allow-lan,omitempty" json:"allow_lan,omitempty"`
+	Authentication []string          `yaml:"authentication,omitempty" json:"authentication,omitempty"`
+	Hosts          map[string]string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	DNS            *DNSOverride      `yaml:"dns,omitempty" json:"dns,omitempty"`
+	Tun            *TunOverride      `yaml:"tun,omitempty" json:"tun,omitempty"`
+}
+
+//DNSOverride DNS 配置的覆盖项.
+type DNSOverride struct {
+	Enable         *bool           `yaml:"enable,omitempty" json:"enable,omitempty"`
+	IPv6           *bool           `yaml:"ipv6,omitempty" json:"ipv6,omitempty"`
+	EnhancedMode   string          `yaml:"enhanced-mode,omitempty" json:"enhanced_mode,omitempty"`
+	FakeIPRange    string          `yaml:"fake-ip-range,omitempty" json:"fake_ip_range,omitempty"`
+	FakeIPFilter   []string        `yaml:"fake-ip-filter,omitempty" json:"fake_ip_filter,omitempty"`
+	Nameserver     []string        `yaml:"nameserver,omitempty" json:"nameserver,omitempty"`
+	Fallback       []string        `yaml:"fallback,omitempty" json:"fallback,omitempty"`
+	FallbackFilter *FallbackFilter `yaml:"fallback-filter,omitempty" json:"fallback_filter,omitempty"`
+}
+
+//TunOverride TUN 配置的覆盖项.
+type TunOverride struct {
+	Enable              *bool    `yaml:"enable,omitempty" json:"enable,omitempty"`
+	Stack               string   `yaml:"stack,omitempty" json:"stack,omitempty"`
+	DNSHijack           []string `yaml:"dns-hijack,omitempty" json:"dns_hijack,omitempty"`
+	AutoRoute           *bool    `yaml:"auto-route,omitempty" json:"auto_route,omitempty"`
+	AutoDetectInterface *bool    `yaml:"auto-detect-interface,omitempty" json:"auto_detect_interface,omitempty"`
+}
+
+//Validate 检查覆盖项的取值是否合法.
+func (o *Override) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.Port < 0 || o.Port > 65535 {
+		return fmt.Errorf("invalid port -> %d", o.Port)
+	}
+	if o.SocksPort < 0 || o.SocksPort > 65535 {
+		return fmt.Errorf("invalid socks-port -> %d", o.SocksPort)
+	}
+	for _, auth := range o.Authentication {
+		if !strings.Contains(auth, ":") {
+			return fmt.Errorf("invalid authentication, expected user:pass -> %s", auth)
+		}
+	}
+	if o.DNS != nil {
+		switch o.DNS.EnhancedMode {
+		case "", "fake-ip", "redir-host", "normal":
+		default:
+			return fmt.Errorf("invalid dns enhanced-mode -> %s", o.DNS.EnhancedMode)
+		}
+	}
+	if o.Tun != nil {
+		switch strings.ToLower(o.Tun.Stack) {
+		case "", "system", "gvisor":
+		default:
+			return fmt.Errorf("invalid tun stack -> %s", o.Tun.Stack)
+		}
+	}
+	return nil
+}
+
+//Merge 返回在当前覆盖项之上再叠加 other 的结果，other 中设置的字段优先.
+func (o *Override) Merge(other *Override) *Override {
+	if o == nil {
+		return other
+	}
+	if other == nil {
+		return o
+	}
+	out := *o
+	if other.Port != 0 {
+		out.Port = other.Port
+	}
+	if other.SocksPort != 0 {
+		out.SocksPort = other.SocksPort
+	}
+	if other.AllowLan != nil {
+		out.AllowLan = other.AllowLan
+	}
+	if len(other.Authentication) > 0 {
+		out.Authentication = other.Authentication
+	}
+	if len(other.Hosts) > 0 {
+		hosts := make(map[string]string, len(o.Hosts)+len(other.Hosts))
+		for k, v := range o.Hosts {
+			hosts[k] = v
+		}
+		for k, v := range other.Hosts {
+			hosts[k] = v
+		}
+		out.Hosts = hosts
+	}
+	if other.DNS != nil {
+		if o.DNS == nil {
+			out.DNS = other.DNS
+		} else {
+			dns := *o.DNS
+			other.DNS.applyTo(&dns)
+			out.DNS = &dns
+		}
+	}
+	if other.Tun != nil {
+		if o.Tun == nil {
+			out.Tun = other.Tun
+		} else {
+			tun := *o.Tun
+			other.Tun.applyTo(&tun)
+			out.Tun = &tun
+		}
+	}
+	return &out
+}
+
+//Apply 将覆盖项应用到配置上.
+func (o *Override) Apply(c *Config) {
+	if o == nil || c == nil {
+		return
+	}
+	if o.Port != 0 {
+		c.Port = o.Port
+	}
+	if o.SocksPort != 0 {
+		c.SocksPort = o.SocksPort
+	}
+	if o.AllowLan != nil {
+		c.AllowLan = *o.AllowLan
+	}
+	if len(o.Authentication) > 0 {
+		c.Authentication = append([]string(nil), o.Authentication...)
+	}
+	if len(o.Hosts) > 0 {
+		hosts := make(map[string]string, len(c.Hosts)+len(o.Hosts))
+		for k, v := range c.Hosts {
+			hosts[k] = v
+		}
+		for k, v := range o.Hosts {
+			hosts[k] = v
+		}
+		c.Hosts = hosts
+	}
+	if o.DNS != nil {
+		if c.DNS == nil {
+			c.DNS = &DNS{}
+		}
+		dns := o.DNS
+		if dns.Enable != nil {
+			c.DNS.Enable = *dns.Enable
+		}
+		if dns.IPv6 != nil {
+			c.DNS.IPv6 = *dns.IPv6
+		}
+		if dns.EnhancedMode != "" {
+			c.DNS.EnhancedMode = dns.EnhancedMode
+		}
+		if dns.FakeIPRange != "" {
+			c.DNS.FakeIPRange = dns.FakeIPRange
+		}
+		if len(dns.FakeIPFilter) > 0 {
+			c.DNS.FakeIPFilter = append([]string(nil), dns.FakeIPFilter...)
+		}
+		if len(dns.Nameserver) > 0 {
+			c.DNS.Nameserver = append([]string(nil), dns.Nameserver...)
+		}
+		if len(dns.Fallback) > 0 {
+			c.DNS.Fallback = append([]string(nil), dns.Fallback...)
+		}
+		if dns.FallbackFilter != nil {
+			filter := *dns.FallbackFilter
+			filter.IPCIDR = append([]string(nil), dns.FallbackFilter.IPCIDR...)
+			filter.Domain = append([]string(nil), dns.FallbackFilter.Domain...)
+			c.DNS.FallbackFilter = &filter
+		}
+	}
+	if o.Tun != nil {
+		if c.Tun == nil {
+			c.Tun = &Tun{}
+		}
+		if o.Tun.Enable != nil {
+			c.Tun.Enable = *o.Tun.Enable
+		}
+		if o.Tun.Stack != "" {
+			c.Tun.Stack = o.Tun.Stack
+		}
+		if len(o.Tun.DNSHijack) > 0 {
+			c.Tun.DNSHijack = append([]string(nil), o.Tun.DNSHijack...)
+		}
+		if o.Tun.AutoRoute != nil {
+			c.Tun.AutoRoute = *o.Tun.AutoRoute
+		}
+		if o.Tun.AutoDetectInterface != nil {
+			c.Tun.AutoDetectInterface = *o.Tun.AutoDetectInterface
+		}
+	}
+}
+
+//applyTo 将已设置的字段叠加到 dst 上.
+func (o *DNSOverride) applyTo(dst *DNSOverride) {
+	if o.Enable != nil {
+		dst.Enable = o.Enable
+	}
+	if o.IPv6 != nil {
+		dst.IPv6 = o.IPv6
+	}
+	if o.EnhancedMode != "" {
+		dst.EnhancedMode = o.EnhancedMode
+	}
+	if o.FakeIPRange != "" {
+		dst.FakeIPRange = o.FakeIPRange
+	}
+	if len(o.FakeIPFilter) > 0 {
+		dst.FakeIPFilter = o.FakeIPFilter
+	}
+	if len(o.Nameserver) > 0 {
+		dst.Nameserver = o.Nameserver
+	}
+	if len(o.Fallback) > 0 {
+		dst.Fallback = o.Fallback
+	}
+	if o.FallbackFilter != nil {
+		dst.FallbackFilter = o.FallbackFilter
+	}
+}
+
+//applyTo 将已设置的字段叠加到 dst 上.
+func (o *TunOverride) applyTo(dst *TunOverride) {
+	if o.Enable != nil {
+		dst.Enable = o.Enable
+	}
+	if o.Stack != "" {
+		dst.Stack = o.Stack
+	}
+	if len(o.DNSHijack) > 0 {
+		dst.DNSHijack = o.DNSHijack
+	}
+	if o.AutoRoute != nil {
+		dst.AutoRoute = o.AutoRoute
+	}
+	if o.AutoDetectInterface != nil {
+		dst.AutoDetectInterface = o.AutoDetectInterface
+	}
+}
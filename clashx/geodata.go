@@ -0,0 +1,228 @@
+package clashx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	//SchemeGeoSite 引用 V2Ray geosite.dat 中分类的规则集地址前缀，如 geosite:cn、geosite:category-ads-all@ads
+	SchemeGeoSite = "geosite:"
+	//SchemeGeoIP 引用 V2Ray geoip.dat 中分类的规则集地址前缀，如 geoip:private
+	SchemeGeoIP = "geoip:"
+)
+
+//geosite.dat 中域名的匹配方式
+const (
+	geoDomainPlain  = 0
+	geoDomainRegex  = 1
+	geoDomainSuffix = 2
+	geoDomainFull   = 3
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+//protoReader 读取 protobuf 编码的消息，只支持 geosite.dat / geoip.dat 用到的字段类型.
+type protoReader struct {
+	data []byte
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if len(r.data) == 0 {
+			return 0, errTruncated
+		}
+		b := r.data[0]
+		r.data = r.data[1:]
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("invalid protobuf varint")
+}
+
+//next 读取下一个字段，返回字段编号、类型以及值，长度分隔类型的值为 data，其余类型的值为 v.
+func (r *protoReader) next() (field int, wireType int, v uint64, data []byte, err error) {
+	key, err := r.varint()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	field, wireType = int(key>>3), int(key&7)
+	switch wireType {
+	case 0:
+		v, err = r.varint()
+	case 1, 5:
+		size := 8
+		if wireType == 5 {
+			size = 4
+		}
+		if len(r.data) < size {
+			return 0, 0, 0, nil, errTruncated
+		}
+		r.data = r.data[size:]
+	case 2:
+		var n uint64
+		if n, err = r.varint(); err != nil {
+			return 0, 0, 0, nil, err
+		}
+		if uint64(len(r.data)) < n {
+			return 0, 0, 0, nil, errTruncated
+		}
+		data, r.data = r.data[:n], r.data[n:]
+	default:
+		err = fmt.Errorf("unsupported protobuf wire type -> %d", wireType)
+	}
+	return
+}
+
+//geoEntries 遍历 GeoSiteList / GeoIPList 中国家代码为 code 的条目，返回条目的内容.
+func geoEntries(data []byte, code string) ([]byte, error) {
+	list := &protoReader{data: data}
+	for len(list.data) > 0 {
+		field, wireType, _, entry, err := list.next()
+		if err != nil {
+			return nil, err
+		}
+		if field != 1 || wireType != 2 {
+			continue
+		}
+		r := &protoReader{data: entry}
+		for len(r.data) > 0 {
+			f, t, _, value, err := r.next()
+			if err != nil {
+				return nil, err
+			}
+			if f == 1 && t == 2 {
+				if strings.EqualFold(string(value), code) {
+					return entry, nil
+				}
+				break
+			}
+		}
+	}
+	return nil, fmt.Errorf("category does not exist -> %s", code)
+}
+
+//ExpandGeoSite 将 geosite.dat 中的分类展开为规则条目，category 可以使用 @ 指定属性，如 category-ads-all@ads.
+// 正则类型的域名无法转换为 clash 规则，会被忽略.
+func ExpandGeoSite(data []byte, category string) ([]string, error) {
+	code, attr := category, ""
+	if i := strings.IndexByte(category, '@'); i >= 0 {
+		code, attr = category[:i], category[i+1:]
+	}
+	entry, err := geoEntries(data, code)
+	if err != nil {
+		return nil, err
+	}
+	var payload []string
+	r := &protoReader{data: entry}
+	for len(r.data) > 0 {
+		field, wireType, _, domain, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if field != 2 || wireType != 2 {
+			continue
+		}
+		var (
+			kind  uint64
+			value string
+			attrs []string
+		)
+		d := &protoReader{data: domain}
+		for len(d.data) > 0 {
+			f, t, v, b, err := d.next()
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case f == 1 && t == 0:
+				kind = v
+			case f == 2 && t == 2:
+				value = strings.ToLower(string(b))
+			case f == 3 && t == 2:
+				a := &protoReader{data: b}
+				for len(a.data) > 0 {
+					af, at, _, ab, err := a.next()
+					if err != nil {
+						return nil, err
+					}
+					if af == 1 && at == 2 {
+						attrs = append(attrs, string(ab))
+					}
+				}
+			}
+		}
+		if attr != "" && !containsFold(attrs, attr) {
+			continue
+		}
+		switch kind {
+		case geoDomainPlain:
+			payload = append(payload, RuleDomainKeyword+","+value)
+		case geoDomainSuffix:
+			payload = append(payload, RuleDomainSuffix+","+value)
+		case geoDomainFull:
+			payload = append(payload, RuleDomain+","+value)
+		}
+	}
+	return compactDomainRules(payload, nil), nil
+}
+
+//ExpandGeoIP 将 geoip.dat 中的分类展开为 IP-CIDR 规则条目.
+func ExpandGeoIP(data []byte, category string) ([]string, error) {
+	entry, err := geoEntries(data, category)
+	if err != nil {
+		return nil, err
+	}
+	var payload []string
+	r := &protoReader{data: entry}
+	for len(r.data) > 0 {
+		field, wireType, v, cidr, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if field == 3 && wireType == 0 && v != 0 {
+			return nil, fmt.Errorf("reverse match is not supported -> %s", category)
+		}
+		if field != 2 || wireType != 2 {
+			continue
+		}
+		var (
+			ip     net.IP
+			prefix uint64
+		)
+		c := &protoReader{data: cidr}
+		for len(c.data) > 0 {
+			f, t, cv, b, err := c.next()
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case f == 1 && t == 2:
+				ip = net.IP(b)
+			case f == 2 && t == 0:
+				prefix = cv
+			}
+		}
+		switch len(ip) {
+		case net.IPv4len:
+			payload = append(payload, fmt.Sprintf("%s,%s/%d", RuleIPCIDR, ip, prefix))
+		case net.IPv6len:
+			payload = append(payload, fmt.Sprintf("%s,%s/%d", RuleIPCIDR6, ip, prefix))
+		}
+	}
+	return payload, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
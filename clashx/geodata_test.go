@@ -0,0 +1,133 @@
+package clashx
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+//以下函数按 protobuf 编码生成测试用的 geosite.dat / geoip.dat 内容
+
+func protoVarint(v uint64) []byte {
+	var b []byte
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func protoBytes(field int, data []byte) []byte {
+	b := protoVarint(uint64(field<<3 | 2))
+	b = append(b, protoVarint(uint64(len(data)))...)
+	return append(b, data...)
+}
+
+func protoUint(field int, v uint64) []byte {
+	return append(protoVarint(uint64(field<<3)), protoVarint(v)...)
+}
+
+func protoJoin(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func testGeoDomain(kind uint64, value string, attrs ...string) []byte {
+	b := protoJoin(protoUint(1, kind), protoBytes(2, []byte(value)))
+	for _, attr := range attrs {
+		b = append(b, protoBytes(3, protoJoin(protoBytes(1, []byte(attr)), protoUint(2, 1)))...)
+	}
+	return protoBytes(2, b)
+}
+
+func testGeoCIDR(cidr string) []byte {
+	ip, ipNet, _ := net.ParseCIDR(cidr)
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	ones, _ := ipNet.Mask.Size()
+	return protoBytes(2, protoJoin(protoBytes(1, ip), protoUint(2, uint64(ones))))
+}
+
+func TestExpandGeoSite(t *testing.T) {
+	data := protoJoin(
+		protoBytes(1, protoJoin(
+			protoBytes(1, []byte("CN")),
+			testGeoDomain(geoDomainSuffix, "Baidu.com"),
+			testGeoDomain(geoDomainFull, "www.qq.com"),
+			testGeoDomain(geoDomainPlain, "taobao"),
+			testGeoDomain(geoDomainRegex, `^.*\.cn$`),
+			testGeoDomain(geoDomainSuffix, "map.baidu.com"),
+			//未知字段会被跳过
+			protoUint(9, 1),
+		)),
+		protoBytes(1, protoJoin(
+			protoBytes(1, []byte("CATEGORY-ADS-ALL")),
+			testGeoDomain(geoDomainSuffix, "ads.com", "ads"),
+			testGeoDomain(geoDomainFull, "track.net"),
+		)),
+	)
+	tests := []struct {
+		category string
+		data     []byte
+		want     []string
+		err      bool
+	}{
+		{category: "cn", data: data, want: []string{"DOMAIN-SUFFIX,baidu.com", "DOMAIN,www.qq.com", "DOMAIN-KEYWORD,taobao"}},
+		{category: "category-ads-all", data: data, want: []string{"DOMAIN-SUFFIX,ads.com", "DOMAIN,track.net"}},
+		{category: "category-ads-all@ads", data: data, want: []string{"DOMAIN-SUFFIX,ads.com"}},
+		{category: "category-ads-all@cn", data: data, want: []string{}},
+		{category: "missing", data: data, err: true},
+		{category: "cn", data: data[:30], err: true},
+	}
+	for _, tt := range tests {
+		got, err := ExpandGeoSite(tt.data, tt.category)
+		if (err != nil) != tt.err {
+			t.Errorf("ExpandGeoSite(%q) error = %v, want error %v", tt.category, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandGeoSite(%q) = %q, want %q", tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestExpandGeoIP(t *testing.T) {
+	data := protoJoin(
+		protoBytes(1, protoJoin(
+			protoBytes(1, []byte("PRIVATE")),
+			testGeoCIDR("10.0.0.0/8"),
+			testGeoCIDR("192.168.0.0/16"),
+			testGeoCIDR("fc00::/7"),
+		)),
+		protoBytes(1, protoJoin(
+			protoBytes(1, []byte("NOT-CN")),
+			testGeoCIDR("1.0.1.0/24"),
+			protoUint(3, 1),
+		)),
+	)
+	tests := []struct {
+		category string
+		data     []byte
+		want     []string
+		err      bool
+	}{
+		{category: "private", data: data, want: []string{"IP-CIDR,10.0.0.0/8", "IP-CIDR,192.168.0.0/16", "IP-CIDR6,fc00::/7"}},
+		{category: "not-cn", data: data, err: true},
+		{category: "cn", data: data, err: true},
+		{category: "private", data: data[:20], err: true},
+	}
+	for _, tt := range tests {
+		got, err := ExpandGeoIP(tt.data, tt.category)
+		if (err != nil) != tt.err {
+			t.Errorf("ExpandGeoIP(%q) error = %v, want error %v", tt.category, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandGeoIP(%q) = %q, want %q", tt.category, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package clashx
+
+import (
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+)
+
+//GeoIP 基于 MaxMind mmdb 格式数据库的 IP 归属地查询，可以使用 clash 的 Country.mmdb.
+type GeoIP struct {
+	reader *maxminddb.Reader
+}
+
+//OpenGeoIP 打开 mmdb 数据库.
+func OpenGeoIP(path string) (*GeoIP, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIP{reader: reader}, nil
+}
+
+//Country 查询 IP 所属的国家或地区代码，查询失败时返回空字符串.
+func (g *GeoIP) Country(ip net.IP) string {
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := g.reader.Lookup(ip, &record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+//Close 关闭数据库.
+func (g *GeoIP) Close() error {
+	return g.reader.Close()
+}
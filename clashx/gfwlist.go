@@ -0,0 +1,102 @@
+package clashx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+//domainPattern 合法的域名，用于过滤无法转换的 AutoProxy 规则.
+var domainPattern = regexp.MustCompile(`^(?i:[a-z0-9_](?:[a-z0-9_-]*[a-z0-9_])?)(?:\.(?i:[a-z0-9_](?:[a-z0-9_-]*[a-z0-9_])?))*$`)
+
+//decodeGFWList GFWList 通常经过 base64 编码，无法解码时按明文处理.
+func decodeGFWList(body []byte) []byte {
+	text := strings.Join(strings.Fields(string(body)), "")
+	if b, err := base64.StdEncoding.DecodeString(text); err == nil {
+		return b
+	}
+	if b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(text, "=")); err == nil {
+		return b
+	}
+	return body
+}
+
+//parseAutoProxyRule 将一条 AutoProxy 规则转换为规则条目，无法转换时返回 false.
+func parseAutoProxyRule(line string) (string, bool) {
+	switch {
+	case strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/"):
+		//正则规则无法转换为 clash 规则
+		return "", false
+	case strings.HasPrefix(line, "||"):
+		return hostRule(autoProxyHost(line[2:]), RuleDomainSuffix)
+	case strings.HasPrefix(line, "|"):
+		return hostRule(autoProxyHost(line[1:]), RuleDomain)
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(autoProxyHost(line), "*."), ".")
+	if !strings.Contains(host, ".") {
+		return hostRule(host, RuleDomainKeyword)
+	}
+	return hostRule(host, RuleDomainSuffix)
+}
+
+//hostRule 生成匹配主机的规则条目，主机为 IP 时生成 IP-CIDR 规则.
+func hostRule(host, domainType string) (string, bool) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() == nil {
+			return RuleIPCIDR6 + "," + host + "/128", true
+		}
+		return RuleIPCIDR + "," + host + "/32", true
+	}
+	if !domainPattern.MatchString(host) {
+		return "", false
+	}
+	return domainType + "," + host, true
+}
+
+//autoProxyHost 取出 AutoProxy 规则中的主机名.
+func autoProxyHost(s string) string {
+	if i := strings.Index(s, "://"); i >= 0 {
+		if u, err := url.Parse(s); err == nil && u.Hostname() != "" {
+			return strings.ToLower(u.Hostname())
+		}
+		s = s[i+3:]
+	}
+	if i := strings.IndexAny(s, "/^:?"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
+
+//parseGFWList 解析 GFWList，@@ 开头的例外规则转换为 DIRECT 策略的条目并放在其余条目之前，正则规则会被忽略，
+// 重复以及已被上级域名覆盖的条目会被合并.
+func parseGFWList(body []byte, behavior string) ([]string, error) {
+	var payload, exceptions, ignored []string
+	scanner := bufio.NewScanner(bytes.NewReader(decodeGFWList(body)))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		if strings.HasPrefix(line, "@@") {
+			if item, ok := parseAutoProxyRule(line[2:]); ok {
+				exceptions = append(exceptions, item)
+			} else {
+				ignored = append(ignored, line)
+			}
+			continue
+		}
+		if item, ok := parseAutoProxyRule(line); ok {
+			payload = append(payload, item)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	logIgnoredExceptions(FormatGFWList, ignored)
+	return compactDomainRules(payload, exceptions), nil
+}
@@ -0,0 +1,184 @@
+package clashx
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	GroupSelect      = "select"
+	GroupURLTest     = "url-test"
+	GroupFallback    = "fallback"
+	GroupLoadBalance = "load-balance"
+	GroupRelay       = "relay"
+)
+
+//builtinPolicies clash 内置的策略，可以直接在分组和规则中引用.
+var builtinPolicies = map[string]bool{
+	"DIRECT": true,
+	"REJECT": true,
+}
+
+func (m *ProxyGroup) clone() *ProxyGroup {
+	group := *m
+	group.Proxies = append([]string(nil), m.Proxies...)
+	if m.Lazy != nil {
+		lazy := *m.Lazy
+		group.Lazy = &lazy
+	}
+	return &group
+}
+
+func (m *ProxyGroup) addProxy(name string) {
+	for _, p := range m.Proxies {
+		if p == name {
+			return
+		}
+	}
+	m.Proxies = append(m.Proxies, name)
+}
+
+//autoFill 是否自动加入订阅中的全部节点，relay 分组的代理链是固定的，不自动加入.
+func (m *ProxyGroup) autoFill() bool {
+	return m.Type != GroupRelay
+}
+
+//setDefaults 为需要测速的分组补全测速地址和间隔.
+func (m *ProxyGroup) setDefaults() {
+	switch m.Type {
+	case GroupURLTest, GroupFallback, GroupLoadBalance:
+		if m.Url == "" {
+			m.Url = defaultTestUrl
+		}
+		if m.Interval <= 0 {
+			m.Interval = defaultTestInterval
+		}
+	}
+}
+
+//validate 检查分组自身的配置是否合法，不检查成员是否存在.
+func (m *ProxyGroup) validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("proxy group name is empty")
+	}
+	switch m.Type {
+	case GroupSelect, GroupURLTest, GroupFallback:
+	case GroupLoadBalance:
+		switch m.Strategy {
+		case "", "consistent-hashing", "round-robin":
+		default:
+			return fmt.Errorf("invalid load-balance strategy -> %s %s", m.Name, m.Strategy)
+		}
+	case GroupRelay:
+		if len(m.Proxies) < 2 {
+			return fmt.Errorf("relay group requires at least two proxies -> %s", m.Name)
+		}
+	default:
+		return fmt.Errorf("invalid proxy group type -> %s %s", m.Name, m.Type)
+	}
+	if m.Tolerance < 0 || m.Interval < 0 {
+		return fmt.Errorf("invalid proxy group tolerance or interval -> %s", m.Name)
+	}
+	return nil
+}
+
+//hasPolicy 是否存在指定名称的节点、分组或内置策略.
+func (m *Config) hasPolicy(name string) bool {
+	if builtinPolicies[name] {
+		return true
+	}
+	for _, p := range m.Proxy {
+		if p.Name == name {
+			return true
+		}
+	}
+	for _, g := range m.ProxyGroup {
+		if g.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+//checkGroups 检查分组是否合法：名称唯一、成员以及节点的 dialer-proxy 均存在、分组和代理链之间没有循环引用.
+func (m *Config) checkGroups() error {
+	proxies := make(map[string]bool, len(m.Proxy))
+	dialers := make(map[string]string)
+	for _, p := range m.Proxy {
+		proxies[p.Name] = true
+		if p.DialerProxy != "" {
+			dialers[p.Name] = p.DialerProxy
+		}
+	}
+	groups := make(map[string]*ProxyGroup, len(m.ProxyGroup))
+	for _, g := range m.ProxyGroup {
+		if err := g.validate(); err != nil {
+			return err
+		}
+		if _, ok := groups[g.Name]; ok || proxies[g.Name] {
+			return fmt.Errorf("duplicate proxy group name -> %s", g.Name)
+		}
+		groups[g.Name] = g
+	}
+	for _, g := range m.ProxyGroup {
+		if len(g.Proxies) == 0 {
+			return fmt.Errorf("proxy group is empty -> %s", g.Name)
+		}
+		for _, name := range g.Proxies {
+			if _, ok := groups[name]; !ok && !proxies[name] && !builtinPolicies[name] {
+				return fmt.Errorf("proxy group %s references unknown proxy or group -> %s", g.Name, name)
+			}
+		}
+	}
+	for name, dialer := range dialers {
+		if _, ok := groups[dialer]; !ok && !proxies[dialer] {
+			return fmt.Errorf("proxy %s references unknown dialer-proxy -> %s", name, dialer)
+		}
+	}
+
+	//深度优先遍历分组成员以及节点 dialer-proxy 的引用关系，检查是否存在循环
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(groups)+len(dialers))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		var children []string
+		if g, ok := groups[name]; ok {
+			children = g.Proxies
+		} else if dialer, ok := dialers[name]; ok {
+			children = []string{dialer}
+		} else {
+			return nil
+		}
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("proxy group reference cycle -> %s -> %s", strings.Join(path, " -> "), name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, child := range children {
+			if err := visit(child); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, g := range m.ProxyGroup {
+		if err := visit(g.Name); err != nil {
+			return err
+		}
+	}
+	for _, p := range m.Proxy {
+		if err := visit(p.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package clashx
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+//MatchTarget 规则匹配测试的目标.
+type MatchTarget struct {
+	Domain string
+	IP     net.IP
+	Port   int
+	//Resolve 解析域名，遇到没有 no-resolve 参数的 IP 规则时才会调用，为空时不解析
+	Resolve func(domain string) (net.IP, error)
+	//GeoIP 查询 IP 所属的国家或地区代码，为空时 GEOIP 规则（GEOIP,LAN 除外）会被跳过
+	GeoIP func(ip net.IP) string
+	//RuleSet 获取规则集的内容，为空时 RULE-SET 规则不会被匹配
+	RuleSet RuleSetLoader
+}
+
+//MatchResult 规则匹配测试的结果.
+type MatchResult struct {
+	//命中的规则及其在规则列表中的位置
+	Rule  string `json:"rule"`
+	Index int    `json:"index"`
+	//命中 RULE-SET 规则时，规则集中命中的条目
+	Payload string `json:"payload,omitempty"`
+	Policy  string `json:"policy"`
+	//从策略开始，依次取 select 分组的第一个成员得到的策略链
+	Path []string `json:"path"`
+	//匹配 IP 规则时解析得到的地址
+	IP string `json:"ip,omitempty"`
+	//匹配过程中被跳过的规则，如无法解析的规则或加载失败的规则集
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+//Match 按顺序使用规则匹配目标，返回第一条命中的规则以及最终使用的策略.
+//SRC-IP-CIDR、SRC-PORT、PROCESS-NAME 等依赖客户端信息的规则不会被匹配.
+func (m *Config) Match(target *MatchTarget) (*MatchResult, error) {
+	if target.Domain == "" && target.IP == nil {
+		return nil, fmt.Errorf("domain and ip are empty")
+	}
+	result := &MatchResult{}
+	matcher := &ruleMatcher{target: target, domain: strings.ToLower(strings.TrimSuffix(target.Domain, ".")), ip: target.IP}
+	ruleSets := make(map[string][]*Rule)
+	for i, line := range m.Rule {
+		r, err := ParseRule(line)
+		if err != nil {
+			result.Skipped = append(result.Skipped, line)
+			continue
+		}
+		matched := false
+		if r.Type == RuleRuleSet {
+			rules, ok := ruleSets[r.Payload]
+			if !ok {
+				rules, err = m.loadMatchRuleSet(r.Payload, target.RuleSet)
+				if err != nil {
+					result.Skipped = append(result.Skipped, line+" -> "+err.Error())
+				}
+				ruleSets[r.Payload] = rules
+			}
+			for _, item := range rules {
+				if target.GeoIP == nil && item.needGeoIP() {
+					result.Skipped = append(result.Skipped, line+" -> "+item.PayloadString()+" "+noMMDBReason)
+					continue
+				}
+				rule := *item
+				rule.Params = mergeParams(item.Params, r.Params)
+				if matcher.match(&rule) {
+					matched = true
+					result.Payload = item.PayloadString()
+					break
+				}
+			}
+		} else if target.GeoIP == nil && r.needGeoIP() {
+			result.Skipped = append(result.Skipped, line+" -> "+noMMDBReason)
+		} else {
+			matched = matcher.match(r)
+		}
+		if matched {
+			result.Rule = r.String()
+			result.Index = i
+			result.Policy = r.Target
+			result.Path = m.policyPath(r.Target)
+			if matcher.ip != nil {
+				result.IP = matcher.ip.String()
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("no rule matched")
+}
+
+//noMMDBReason 没有配置 mmdb 时跳过 GEOIP 规则的原因.
+const noMMDBReason = "no mmdb configured"
+
+//needGeoIP 规则是否需要查询 IP 所属的国家或地区，GEOIP,LAN 不需要.
+func (r *Rule) needGeoIP() bool {
+	return r.Type == RuleGeoIP && !strings.EqualFold(r.Payload, "lan")
+}
+
+func (m *Config) loadMatchRuleSet(name string, load RuleSetLoader) ([]*Rule, error) {
+	provider, ok := m.RuleProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("rule provider does not exist")
+	}
+	if load == nil {
+		return nil, fmt.Errorf("rule provider is not loaded")
+	}
+	payload, err := load(name, provider)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*Rule, 0, len(payload))
+	//例外规则已作为普通规则放在 RULE-SET 规则之前
+	items, _ := SplitExceptions(payload)
+	for _, item := range items {
+		if r, err := parsePayloadRule(item); err == nil {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+//policyPath 从策略开始依次取 select 分组的第一个成员，直到遇到节点、内置策略或其他类型的分组.
+func (m *Config) policyPath(policy string) []string {
+	groups := make(map[string]*ProxyGroup, len(m.ProxyGroup))
+	for _, g := range m.ProxyGroup {
+		groups[g.Name] = g
+	}
+	path := []string{policy}
+	visited := map[string]bool{policy: true}
+	for {
+		g, ok := groups[policy]
+		if !ok || g.Type != GroupSelect || len(g.Proxies) == 0 || visited[g.Proxies[0]] {
+			return path
+		}
+		policy = g.Proxies[0]
+		visited[policy] = true
+		path = append(path, policy)
+	}
+}
+
+type ruleMatcher struct {
+	target   *MatchTarget
+	domain   string
+	ip       net.IP
+	resolved bool
+}
+
+//resolve 在需要时解析域名，只解析一次.
+func (m *ruleMatcher) resolve(noResolve bool) net.IP {
+	if m.ip != nil || noResolve || m.resolved || m.domain == "" || m.target.Resolve == nil {
+		return m.ip
+	}
+	m.resolved = true
+	if ip, err := m.target.Resolve(m.domain); err == nil {
+		m.ip = ip
+	}
+	return m.ip
+}
+
+func (m *ruleMatcher) match(r *Rule) bool {
+	payload := strings.ToLower(r.Payload)
+	switch r.Type {
+	case RuleDomain:
+		return m.domain != "" && m.domain == payload
+	case RuleDomainSuffix:
+		return m.domain != "" && (m.domain == payload || strings.HasSuffix(m.domain, "."+payload))
+	case RuleDomainKeyword:
+		return m.domain != "" && strings.Contains(m.domain, payload)
+	case RuleIPCIDR, RuleIPCIDR6:
+		_, ipNet, err := net.ParseCIDR(r.Payload)
+		if err != nil {
+			return false
+		}
+		ip := m.resolve(r.noResolve())
+		return ip != nil && ipNet.Contains(ip)
+	case RuleGeoIP:
+		ip := m.resolve(r.noResolve())
+		if ip == nil {
+			return false
+		}
+		if payload == "lan" {
+			return isPrivateIP(ip)
+		}
+		return m.target.GeoIP != nil && strings.EqualFold(m.target.GeoIP(ip), r.Payload)
+	case RuleDstPort:
+		port, err := strconv.Atoi(r.Payload)
+		return err == nil && m.target.Port != 0 && port == m.target.Port
+	case RuleMatch, RuleFinal:
+		return true
+	}
+	return false
+}
+
+var privateNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "10.0.0.0/8", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "::1/128", "fc00::/7", "fe80::/10"} {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		nets = append(nets, ipNet)
+	}
+	return nets
+}()
+
+func isPrivateIP(ip net.IP) bool {
+	for _, ipNet := range privateNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
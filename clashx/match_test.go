@@ -0,0 +1,153 @@
+package clashx
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	c := &Config{
+		Proxy: []*Proxy{testProxy("香港 01", 8388)},
+		ProxyGroup: []*ProxyGroup{
+			{Name: "Proxy", Type: GroupSelect, Proxies: []string{"HK", "DIRECT"}},
+			{Name: "HK", Type: GroupSelect, Proxies: []string{"香港 01"}},
+			{Name: "Auto", Type: GroupURLTest, Proxies: []string{"香港 01"}},
+		},
+		RuleProviders: map[string]*RuleProvider{
+			"ads": {Type: "http", Behavior: BehaviorClassical, URL: "https://example.com/ads.yaml"},
+		},
+		Rule: []string{
+			"DOMAIN-SUFFIX,google.com,Proxy",
+			"RULE-SET,ads,REJECT",
+			"DOMAIN-KEYWORD,github,Auto",
+			"DST-PORT,22,DIRECT",
+			"IP-CIDR,10.0.0.0/8,DIRECT,no-resolve",
+			"GEOIP,LAN,DIRECT",
+			"IP-CIDR,1.2.3.0/24,HK",
+			"GEOIP,CN,DIRECT",
+			"MATCH,Proxy",
+		},
+	}
+	loadRuleSet := func(name string, provider *RuleProvider) ([]string, error) {
+		return []string{"DOMAIN-SUFFIX,safe.ads.example.com,DIRECT", "GEOIP,KR", "DOMAIN-SUFFIX,ads.example.com"}, nil
+	}
+	hosts := map[string]string{"router.lan": "192.168.1.1", "cdn.example.com": "1.2.3.4", "baidu.com": "114.114.114.114"}
+	resolve := func(domain string) (net.IP, error) {
+		if ip, ok := hosts[domain]; ok {
+			return net.ParseIP(ip), nil
+		}
+		return nil, fmt.Errorf("no such host -> %s", domain)
+	}
+	geoIP := func(ip net.IP) string {
+		if ip.Equal(net.ParseIP("114.114.114.114")) {
+			return "CN"
+		}
+		return "US"
+	}
+	geoIPSkipped := []string{"RULE-SET,ads,REJECT -> GEOIP,KR " + noMMDBReason, "GEOIP,CN,DIRECT -> " + noMMDBReason}
+
+	tests := []struct {
+		name   string
+		target *MatchTarget
+		want   *MatchResult
+	}{
+		{
+			name:   "domain suffix",
+			target: &MatchTarget{Domain: "WWW.Google.com.", RuleSet: loadRuleSet},
+			want:   &MatchResult{Rule: "DOMAIN-SUFFIX,google.com,Proxy", Index: 0, Policy: "Proxy", Path: []string{"Proxy", "HK", "香港 01"}},
+		},
+		{
+			name:   "rule set",
+			target: &MatchTarget{Domain: "x.ads.example.com", RuleSet: loadRuleSet},
+			want: &MatchResult{Rule: "RULE-SET,ads,REJECT", Index: 1, Payload: "DOMAIN-SUFFIX,ads.example.com", Policy: "REJECT", Path: []string{"REJECT"},
+				Skipped: geoIPSkipped[:1]},
+		},
+		{
+			name:   "rule set not loaded",
+			target: &MatchTarget{Domain: "github.com"},
+			want: &MatchResult{Rule: "DOMAIN-KEYWORD,github,Auto", Index: 2, Policy: "Auto", Path: []string{"Auto"},
+				Skipped: []string{"RULE-SET,ads,REJECT -> rule provider is not loaded"}},
+		},
+		{
+			name:   "port",
+			target: &MatchTarget{Domain: "example.org", Port: 22, GeoIP: geoIP},
+			want:   &MatchResult{Rule: "DST-PORT,22,DIRECT", Index: 3, Policy: "DIRECT", Path: []string{"DIRECT"}, Skipped: []string{"RULE-SET,ads,REJECT -> rule provider is not loaded"}},
+		},
+		{
+			name:   "ip",
+			target: &MatchTarget{IP: net.ParseIP("10.1.1.1"), GeoIP: geoIP, RuleSet: loadRuleSet},
+			want:   &MatchResult{Rule: "IP-CIDR,10.0.0.0/8,DIRECT,no-resolve", Index: 4, Policy: "DIRECT", Path: []string{"DIRECT"}, IP: "10.1.1.1"},
+		},
+		{
+			name:   "no-resolve skips resolving",
+			target: &MatchTarget{Domain: "router.lan", Resolve: resolve, GeoIP: geoIP, RuleSet: loadRuleSet},
+			want:   &MatchResult{Rule: "GEOIP,LAN,DIRECT", Index: 5, Policy: "DIRECT", Path: []string{"DIRECT"}, IP: "192.168.1.1"},
+		},
+		{
+			name:   "resolve",
+			target: &MatchTarget{Domain: "cdn.example.com", Resolve: resolve, GeoIP: geoIP, RuleSet: loadRuleSet},
+			want:   &MatchResult{Rule: "IP-CIDR,1.2.3.0/24,HK", Index: 6, Policy: "HK", Path: []string{"HK", "香港 01"}, IP: "1.2.3.4"},
+		},
+		{
+			name:   "geoip",
+			target: &MatchTarget{Domain: "baidu.com", Resolve: resolve, GeoIP: geoIP, RuleSet: loadRuleSet},
+			want:   &MatchResult{Rule: "GEOIP,CN,DIRECT", Index: 7, Policy: "DIRECT", Path: []string{"DIRECT"}, IP: "114.114.114.114"},
+		},
+		{
+			name:   "geoip without mmdb",
+			target: &MatchTarget{Domain: "baidu.com", Resolve: resolve, RuleSet: loadRuleSet},
+			want:   &MatchResult{Rule: "MATCH,Proxy", Index: 8, Policy: "Proxy", Path: []string{"Proxy", "HK", "香港 01"}, IP: "114.114.114.114", Skipped: geoIPSkipped},
+		},
+		{
+			name:   "unresolved domain",
+			target: &MatchTarget{Domain: "unknown.example.org", Resolve: resolve, GeoIP: geoIP, RuleSet: loadRuleSet},
+			want:   &MatchResult{Rule: "MATCH,Proxy", Index: 8, Policy: "Proxy", Path: []string{"Proxy", "HK", "香港 01"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := c.Match(tt.target)
+		if err != nil {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := c.Match(&MatchTarget{}); err == nil {
+		t.Errorf("Match() without domain and ip should fail")
+	}
+	c.Rule = c.Rule[:len(c.Rule)-1]
+	if _, err := c.Match(&MatchTarget{Domain: "example.org"}); err == nil {
+		t.Errorf("Match() without a matching rule should fail")
+	}
+}
+
+func TestPolicyPath(t *testing.T) {
+	c := &Config{
+		ProxyGroup: []*ProxyGroup{
+			{Name: "A", Type: GroupSelect, Proxies: []string{"B"}},
+			{Name: "B", Type: GroupSelect, Proxies: []string{"A"}},
+			{Name: "C", Type: GroupSelect, Proxies: []string{"Auto", "A"}},
+			{Name: "Auto", Type: GroupURLTest, Proxies: []string{"香港 01"}},
+			{Name: "Empty", Type: GroupSelect},
+		},
+	}
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{policy: "A", want: []string{"A", "B"}},
+		{policy: "C", want: []string{"C", "Auto"}},
+		{policy: "Empty", want: []string{"Empty"}},
+		{policy: "DIRECT", want: []string{"DIRECT"}},
+	}
+	for _, tt := range tests {
+		if got := c.policyPath(tt.policy); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("policyPath(%q) = %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package clashx
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+//ruleIndex 记录已出现的规则，用于判断之后的规则是否永远不会被匹配.
+type ruleIndex struct {
+	domains  map[string]int
+	suffixes map[string]int
+	keywords []int
+	//按网段记录的 IP 规则，键为网段，noResolve 中的规则不会对域名进行解析
+	cidrs          map[string]int
+	cidrsNoResolve map[string]int
+	geoip          map[string]int
+	geoipNoResolve map[string]int
+	rules          []*Rule
+}
+
+func newRuleIndex() *ruleIndex {
+	return &ruleIndex{
+		domains:        make(map[string]int),
+		suffixes:       make(map[string]int),
+		cidrs:          make(map[string]int),
+		cidrsNoResolve: make(map[string]int),
+		geoip:          make(map[string]int),
+		geoipNoResolve: make(map[string]int),
+	}
+}
+
+func (r *Rule) noResolve() bool {
+	for _, p := range r.Params {
+		if p == "no-resolve" {
+			return true
+		}
+	}
+	return false
+}
+
+//add 记录一条规则，i 为规则在规则列表中的位置.
+func (idx *ruleIndex) add(i int, r *Rule) {
+	setFirst := func(m map[string]int, key string) {
+		if _, ok := m[key]; !ok {
+			m[key] = i
+		}
+	}
+	payload := strings.ToLower(r.Payload)
+	switch r.Type {
+	case RuleDomain:
+		setFirst(idx.domains, payload)
+	case RuleDomainSuffix:
+		setFirst(idx.suffixes, payload)
+	case RuleDomainKeyword:
+		idx.keywords = append(idx.keywords, i)
+	case RuleIPCIDR, RuleIPCIDR6:
+		if _, ipNet, err := net.ParseCIDR(r.Payload); err == nil {
+			if r.noResolve() {
+				setFirst(idx.cidrsNoResolve, ipNet.String())
+			} else {
+				setFirst(idx.cidrs, ipNet.String())
+			}
+		}
+	case RuleGeoIP:
+		if r.noResolve() {
+			setFirst(idx.geoipNoResolve, payload)
+		} else {
+			setFirst(idx.geoip, payload)
+		}
+	}
+}
+
+//shadowedBy 返回使规则 r 永远不会被匹配的前序规则的位置，不存在时返回 -1.
+func (idx *ruleIndex) shadowedBy(r *Rule) int {
+	payload := strings.ToLower(r.Payload)
+	switch r.Type {
+	case RuleDomain, RuleDomainSuffix:
+		if r.Type == RuleDomain {
+			if i, ok := idx.domains[payload]; ok {
+				return i
+			}
+		}
+		for domain := payload; domain != ""; {
+			if i, ok := idx.suffixes[domain]; ok {
+				return i
+			}
+			dot := strings.IndexByte(domain, '.')
+			if dot < 0 {
+				break
+			}
+			domain = domain[dot+1:]
+		}
+		fallthrough
+	case RuleDomainKeyword:
+		for _, i := range idx.keywords {
+			if strings.Contains(payload, strings.ToLower(idx.rules[i].Payload)) {
+				return i
+			}
+		}
+	case RuleIPCIDR, RuleIPCIDR6:
+		ip, ipNet, err := net.ParseCIDR(r.Payload)
+		if err != nil {
+			return -1
+		}
+		ones, bits := ipNet.Mask.Size()
+		for l := 0; l <= ones; l++ {
+			key := (&net.IPNet{IP: ip.Mask(net.CIDRMask(l, bits)), Mask: net.CIDRMask(l, bits)}).String()
+			if i, ok := idx.cidrs[key]; ok {
+				return i
+			}
+			if i, ok := idx.cidrsNoResolve[key]; ok && r.noResolve() {
+				return i
+			}
+		}
+	case RuleGeoIP:
+		if i, ok := idx.geoip[payload]; ok {
+			return i
+		}
+		if i, ok := idx.geoipNoResolve[payload]; ok && r.noResolve() {
+			return i
+		}
+	}
+	return -1
+}
+
+//OptimizeRules 分析规则列表：移除完全重复的规则，报告因前面存在范围更大的规则而永远不会被匹配的规则，
+//collapse 为 true 时同时移除这些规则. 分析结果会加入 Warnings 并返回.
+func (m *Config) OptimizeRules(collapse bool) []string {
+	var reports []string
+	idx := newRuleIndex()
+	seen := make(map[string]bool, len(m.Rule))
+	rules := make([]string, 0, len(m.Rule))
+	final, duplicates := -1, 0
+	for _, line := range m.Rule {
+		r, err := ParseRule(line)
+		if err != nil {
+			rules = append(rules, line)
+			continue
+		}
+		text := r.String()
+		if seen[text] {
+			duplicates++
+			continue
+		}
+		seen[text] = true
+
+		shadow := -1
+		if final >= 0 {
+			shadow = final
+		} else {
+			shadow = idx.shadowedBy(r)
+		}
+		if shadow >= 0 {
+			by := idx.rules[shadow]
+			if by.Target == r.Target {
+				reports = append(reports, fmt.Sprintf("redundant rule -> %s covered by %s", text, by))
+			} else {
+				reports = append(reports, fmt.Sprintf("shadowed rule -> %s never matches because of %s", text, by))
+			}
+			if collapse {
+				continue
+			}
+		}
+		idx.rules = append(idx.rules, r)
+		i := len(idx.rules) - 1
+		idx.add(i, r)
+		if r.IsFinal() && final < 0 {
+			final = i
+		}
+		rules = append(rules, text)
+	}
+	if duplicates > 0 {
+		reports = append(reports, fmt.Sprintf("%d duplicate rules removed", duplicates))
+	}
+	m.Rule = rules
+	m.Warnings = append(m.Warnings, reports...)
+	return reports
+}
@@ -0,0 +1,78 @@
+package clashx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptimizeRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		collapse bool
+		want     []string
+		reports  []string
+	}{
+		{
+			name:    "duplicate",
+			rules:   []string{"DOMAIN,a.com,Proxy", "DOMAIN,a.com,Proxy", "MATCH,DIRECT"},
+			want:    []string{"DOMAIN,a.com,Proxy", "MATCH,DIRECT"},
+			reports: []string{"1 duplicate rules removed"},
+		},
+		{
+			name:     "redundant domain",
+			rules:    []string{"DOMAIN-SUFFIX,a.com,Proxy", "DOMAIN,www.a.com,Proxy", "DOMAIN-SUFFIX,b.a.com,Proxy", "MATCH,DIRECT"},
+			collapse: true,
+			want:     []string{"DOMAIN-SUFFIX,a.com,Proxy", "MATCH,DIRECT"},
+			reports: []string{
+				"redundant rule -> DOMAIN,www.a.com,Proxy covered by DOMAIN-SUFFIX,a.com,Proxy",
+				"redundant rule -> DOMAIN-SUFFIX,b.a.com,Proxy covered by DOMAIN-SUFFIX,a.com,Proxy",
+			},
+		},
+		{
+			name:    "shadowed rules are only reported without collapse",
+			rules:   []string{"DOMAIN-KEYWORD,google,Proxy", "DOMAIN-SUFFIX,google.cn,DIRECT", "MATCH,DIRECT"},
+			want:    []string{"DOMAIN-KEYWORD,google,Proxy", "DOMAIN-SUFFIX,google.cn,DIRECT", "MATCH,DIRECT"},
+			reports: []string{"shadowed rule -> DOMAIN-SUFFIX,google.cn,DIRECT never matches because of DOMAIN-KEYWORD,google,Proxy"},
+		},
+		{
+			name:  "narrower rule first",
+			rules: []string{"DOMAIN,www.a.com,DIRECT", "DOMAIN-SUFFIX,a.com,Proxy", "DOMAIN-SUFFIX,aa.com,Proxy", "MATCH,DIRECT"},
+			want:  []string{"DOMAIN,www.a.com,DIRECT", "DOMAIN-SUFFIX,a.com,Proxy", "DOMAIN-SUFFIX,aa.com,Proxy", "MATCH,DIRECT"},
+		},
+		{
+			name:     "ip rules",
+			rules:    []string{"IP-CIDR,10.0.0.0/8,DIRECT", "IP-CIDR,10.1.0.0/16,DIRECT,no-resolve", "IP-CIDR,192.168.0.0/16,DIRECT,no-resolve", "IP-CIDR,192.168.1.0/24,DIRECT", "MATCH,Proxy"},
+			collapse: true,
+			want:     []string{"IP-CIDR,10.0.0.0/8,DIRECT", "IP-CIDR,192.168.0.0/16,DIRECT,no-resolve", "IP-CIDR,192.168.1.0/24,DIRECT", "MATCH,Proxy"},
+			reports:  []string{"redundant rule -> IP-CIDR,10.1.0.0/16,DIRECT,no-resolve covered by IP-CIDR,10.0.0.0/8,DIRECT"},
+		},
+		{
+			name:     "geoip",
+			rules:    []string{"GEOIP,CN,DIRECT", "GEOIP,cn,DIRECT,no-resolve", "MATCH,Proxy"},
+			collapse: true,
+			want:     []string{"GEOIP,CN,DIRECT", "MATCH,Proxy"},
+			reports:  []string{"redundant rule -> GEOIP,cn,DIRECT,no-resolve covered by GEOIP,CN,DIRECT"},
+		},
+		{
+			name:     "after MATCH",
+			rules:    []string{"MATCH,DIRECT", "DOMAIN,a.com,Proxy"},
+			collapse: true,
+			want:     []string{"MATCH,DIRECT"},
+			reports:  []string{"shadowed rule -> DOMAIN,a.com,Proxy never matches because of MATCH,DIRECT"},
+		},
+	}
+	for _, tt := range tests {
+		c := &Config{Rule: tt.rules}
+		reports := c.OptimizeRules(tt.collapse)
+		if !reflect.DeepEqual(c.Rule, tt.want) {
+			t.Errorf("%s: rules = %q, want %q", tt.name, c.Rule, tt.want)
+		}
+		if !reflect.DeepEqual(reports, tt.reports) {
+			t.Errorf("%s: reports = %q, want %q", tt.name, reports, tt.reports)
+		}
+		if !reflect.DeepEqual(c.Warnings, tt.reports) {
+			t.Errorf("%s: warnings = %q, want %q", tt.name, c.Warnings, tt.reports)
+		}
+	}
+}
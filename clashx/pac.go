@@ -0,0 +1,146 @@
+package clashx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+//pacScript PAC 文件的匹配逻辑，规则依次为 [类型, 匹配内容, 掩码, 是否走代理, 是否解析域名].
+// 连续的 DOMAIN 和 DOMAIN-SUFFIX 规则合并为一条 DOMAINS 规则，匹配内容是以 "=域名" 和 ".后缀" 为键、
+// [规则序号, 是否走代理] 为值的对象，按主机名逐级查找，多条命中时使用序号最小的规则，以免大型规则集逐条比较.
+const pacScript = `var proxy = %s;
+var rules = %s;
+
+function FindProxyForURL(url, host) {
+    host = host.toLowerCase();
+    var ip = null;
+    var resolved = false;
+    for (var i = 0; i < rules.length; i++) {
+        var r = rules[i];
+        var matched = false;
+        var useProxy = r[3];
+        switch (r[0]) {
+        case "DOMAINS":
+            var hit = lookupDomain(r[1], host);
+            if (hit !== null) {
+                matched = true;
+                useProxy = hit[1];
+            }
+            break;
+        case "DOMAIN-KEYWORD":
+            matched = host.indexOf(r[1]) >= 0;
+            break;
+        case "IP-CIDR":
+            if (isIPv4(host)) {
+                matched = isInNet(host, r[1], r[2]);
+            } else if (r[4]) {
+                if (!resolved) {
+                    ip = dnsResolve(host);
+                    resolved = true;
+                }
+                matched = ip !== null && isInNet(ip, r[1], r[2]);
+            }
+            break;
+        case "MATCH":
+            matched = true;
+            break;
+        }
+        if (matched) {
+            return useProxy ? proxy : "DIRECT";
+        }
+    }
+    return "DIRECT";
+}
+
+function lookupDomain(set, host) {
+    var hit = Object.prototype.hasOwnProperty.call(set, "=" + host) ? set["=" + host] : null;
+    var suffix = host;
+    while (true) {
+        if (Object.prototype.hasOwnProperty.call(set, "." + suffix)) {
+            var h = set["." + suffix];
+            if (hit === null || h[0] < hit[0]) {
+                hit = h;
+            }
+        }
+        var i = suffix.indexOf(".");
+        if (i < 0) {
+            return hit;
+        }
+        suffix = suffix.substring(i + 1);
+    }
+}
+
+function isIPv4(host) {
+    return /^\d+\.\d+\.\d+\.\d+$/.test(host);
+}
+`
+
+//pacDomains PAC 中由连续的 DOMAIN 和 DOMAIN-SUFFIX 规则合并而成的规则类型.
+const pacDomains = "DOMAINS"
+
+//PAC 将规则中的 DOMAIN、DOMAIN-SUFFIX、DOMAIN-KEYWORD、IP-CIDR 以及 MATCH 规则编译为 PAC 文件，
+// 策略为 DIRECT 的规则直连，其余规则交给 host 上 clash 的 http 或 socks 端口处理.
+// 无法在 PAC 中表达的规则会被忽略，RULE-SET 规则需要事先展开.
+func (m *Config) PAC(host string) (string, error) {
+	var proxies []string
+	if m.Port > 0 {
+		proxies = append(proxies, fmt.Sprintf("PROXY %s:%d", host, m.Port))
+	}
+	if m.SocksPort > 0 {
+		proxies = append(proxies, fmt.Sprintf("SOCKS5 %s:%d", host, m.SocksPort), fmt.Sprintf("SOCKS %s:%d", host, m.SocksPort))
+	}
+	if len(proxies) == 0 {
+		return "", fmt.Errorf("port and socks-port are not set")
+	}
+
+	rules := make([][]interface{}, 0, len(m.Rule))
+	for _, line := range m.Rule {
+		r, err := ParseRule(line)
+		if err != nil {
+			continue
+		}
+		proxy := r.Target != "DIRECT"
+		payload := strings.ToLower(r.Payload)
+		switch r.Type {
+		case RuleDomain, RuleDomainSuffix:
+			key := "=" + payload
+			if r.Type == RuleDomainSuffix {
+				key = "." + payload
+			}
+			var set map[string][]interface{}
+			if n := len(rules); n > 0 && rules[n-1][0] == pacDomains {
+				set = rules[n-1][1].(map[string][]interface{})
+			} else {
+				set = make(map[string][]interface{})
+				rules = append(rules, []interface{}{pacDomains, set, "", false, false})
+			}
+			//同一个键只保留第一条规则
+			if _, ok := set[key]; !ok {
+				set[key] = []interface{}{len(set), proxy}
+			}
+		case RuleDomainKeyword:
+			rules = append(rules, []interface{}{r.Type, payload, "", proxy, false})
+		case RuleIPCIDR:
+			_, ipNet, err := net.ParseCIDR(r.Payload)
+			if err != nil || ipNet.IP.To4() == nil {
+				continue
+			}
+			mask := net.IP(ipNet.Mask).String()
+			rules = append(rules, []interface{}{r.Type, ipNet.IP.String(), mask, proxy, !r.noResolve()})
+		case RuleMatch, RuleFinal:
+			rules = append(rules, []interface{}{RuleMatch, "", "", proxy, false})
+		}
+	}
+	proxyJSON, _ := json.Marshal(strings.Join(proxies, "; "))
+	lines := make([]string, 0, len(rules))
+	for _, r := range rules {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, "    "+string(b))
+	}
+	return fmt.Sprintf(pacScript, proxyJSON, "[\n"+strings.Join(lines, ",\n")+"\n]"), nil
+}
@@ -0,0 +1,104 @@
+package clashx
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//pacLookup 与 PAC 中的 lookupDomain 相同，按主机名逐级查找合并后的域名规则，返回序号最小的命中规则.
+func pacLookup(set map[string]interface{}, host string) []interface{} {
+	var hit []interface{}
+	if h, ok := set["="+host]; ok {
+		hit = h.([]interface{})
+	}
+	for suffix := host; ; {
+		if h, ok := set["."+suffix]; ok {
+			if h := h.([]interface{}); hit == nil || h[0].(float64) < hit[0].(float64) {
+				hit = h
+			}
+		}
+		i := strings.Index(suffix, ".")
+		if i < 0 {
+			return hit
+		}
+		suffix = suffix[i+1:]
+	}
+}
+
+func TestPAC(t *testing.T) {
+	c := &Config{
+		Port:      7890,
+		SocksPort: 7891,
+		Rule: []string{
+			"DOMAIN-SUFFIX,Google.com,Proxy",
+			"DOMAIN,mail.google.com,DIRECT",
+			"DOMAIN-SUFFIX,google.com,DIRECT",
+			"DOMAIN-SUFFIX,cn,DIRECT",
+			"DOMAIN-KEYWORD,ads,REJECT",
+			"DOMAIN,ads.example.cn,DIRECT",
+			"GEOIP,CN,DIRECT",
+			"IP-CIDR,10.0.0.0/8,DIRECT,no-resolve",
+			"IP-CIDR6,fc00::/7,DIRECT",
+			"IP-CIDR,1.2.3.4/24,Proxy",
+			"MATCH,Proxy",
+		},
+	}
+	pac, err := c.PAC("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `var proxy = "PROXY 127.0.0.1:7890; SOCKS5 127.0.0.1:7891; SOCKS 127.0.0.1:7891";`; !strings.HasPrefix(pac, want) {
+		t.Errorf("PAC() does not start with %q", want)
+	}
+	start := strings.Index(pac, "var rules = ")
+	end := strings.Index(pac, ";\n\nfunction FindProxyForURL")
+	if start < 0 || end < start {
+		t.Fatalf("PAC() has no rules:\n%s", pac)
+	}
+	var rules []interface{}
+	if err := json.Unmarshal([]byte(pac[start+len("var rules = "):end]), &rules); err != nil {
+		t.Fatal(err)
+	}
+	var want []interface{}
+	_ = json.Unmarshal([]byte(`[
+		["DOMAINS", {".google.com": [0, true], "=mail.google.com": [1, false], ".cn": [2, false]}, "", false, false],
+		["DOMAIN-KEYWORD", "ads", "", true, false],
+		["DOMAINS", {"=ads.example.cn": [0, false]}, "", false, false],
+		["IP-CIDR", "10.0.0.0", "255.0.0.0", false, false],
+		["IP-CIDR", "1.2.3.0", "255.255.255.0", true, true],
+		["MATCH", "", "", true, false]
+	]`), &want)
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("rules = %v, want %v", rules, want)
+	}
+
+	//同一组中多条规则命中时使用最先出现的规则
+	set := rules[0].([]interface{})[1].(map[string]interface{})
+	lookups := []struct {
+		host  string
+		hit   bool
+		proxy bool
+	}{
+		{host: "mail.google.com", hit: true, proxy: true},
+		{host: "google.com", hit: true, proxy: true},
+		{host: "www.google.com.cn", hit: true, proxy: false},
+		{host: "notgoogle.com"},
+	}
+	for _, tt := range lookups {
+		hit := pacLookup(set, tt.host)
+		if (hit != nil) != tt.hit || (hit != nil && hit[1] != tt.proxy) {
+			t.Errorf("lookup(%q) = %v, want hit %v proxy %v", tt.host, hit, tt.hit, tt.proxy)
+		}
+	}
+
+	c.SocksPort = 0
+	if pac, _ := c.PAC("10.0.0.2"); !strings.HasPrefix(pac, `var proxy = "PROXY 10.0.0.2:7890";`) {
+		t.Errorf("PAC() without socks-port uses %q", strings.SplitN(pac, "\n", 2)[0])
+	}
+	c.Port = 0
+	if _, err := c.PAC("127.0.0.1"); err == nil {
+		t.Errorf("PAC() without port and socks-port should fail")
+	}
+}
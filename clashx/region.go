@@ -0,0 +1,61 @@
+package clashx
+
+import (
+	"regexp"
+)
+
+//Region 节点所属地区.
+type Region struct {
+	//ISO 3166 国家或地区代码
+	Code  string
+	Name  string
+	Emoji string
+	//用于从节点名称中识别地区的正则
+	Pattern *regexp.Regexp
+}
+
+//Regions 内置的地区识别规则，按顺序匹配.
+var Regions = []*Region{
+	{Code: "HK", Name: "香港", Emoji: "🇭🇰", Pattern: regionPattern("HK", `香港|(?i:hong ?kong)|🇭🇰`)},
+	{Code: "TW", Name: "台湾", Emoji: "🇹🇼", Pattern: regionPattern("TW", `台湾|臺灣|台北|新北|彰化|(?i:taiwan)|🇹🇼`)},
+	{Code: "SG", Name: "新加坡", Emoji: "🇸🇬", Pattern: regionPattern("SG", `新加坡|狮城|(?i:singapore)|🇸🇬`)},
+	{Code: "JP", Name: "日本", Emoji: "🇯🇵", Pattern: regionPattern("JP", `日本|东京|大阪|埼玉|(?i:japan|tokyo|osaka)|🇯🇵`)},
+	{Code: "KR", Name: "韩国", Emoji: "🇰🇷", Pattern: regionPattern("KR", `韩国|韓國|首尔|(?i:korea|seoul)|🇰🇷`)},
+	{Code: "US", Name: "美国", Emoji: "🇺🇸", Pattern: regionPattern("US", `美国|美國|洛杉矶|圣何塞|硅谷|西雅图|芝加哥|纽约|(?i:united states|america|los angeles|san jose|seattle)|🇺🇸`)},
+	{Code: "GB", Name: "英国", Emoji: "🇬🇧", Pattern: regionPattern("UK|GB", `英国|英國|伦敦|(?i:united kingdom|britain|london)|🇬🇧`)},
+	{Code: "DE", Name: "德国", Emoji: "🇩🇪", Pattern: regionPattern("DE", `德国|德國|法兰克福|(?i:germany|frankfurt)|🇩🇪`)},
+	{Code: "FR", Name: "法国", Emoji: "🇫🇷", Pattern: regionPattern("FR", `法国|法國|巴黎|(?i:france|paris)|🇫🇷`)},
+	{Code: "NL", Name: "荷兰", Emoji: "🇳🇱", Pattern: regionPattern("NL", `荷兰|荷蘭|阿姆斯特丹|(?i:netherlands|amsterdam)|🇳🇱`)},
+	{Code: "RU", Name: "俄罗斯", Emoji: "🇷🇺", Pattern: regionPattern("RU", `俄罗斯|俄羅斯|莫斯科|(?i:russia|moscow)|🇷🇺`)},
+	{Code: "CA", Name: "加拿大", Emoji: "🇨🇦", Pattern: regionPattern("CA", `加拿大|多伦多|温哥华|(?i:canada|toronto|vancouver)|🇨🇦`)},
+	{Code: "AU", Name: "澳大利亚", Emoji: "🇦🇺", Pattern: regionPattern("AU", `澳大利亚|澳洲|悉尼|(?i:australia|sydney)|🇦🇺`)},
+	{Code: "IN", Name: "印度", Emoji: "🇮🇳", Pattern: regionPattern("IN", `印度|孟买|(?i:india|mumbai)|🇮🇳`)},
+}
+
+//regionPattern 生成地区识别正则，codes 为大写的地区缩写，只在其前后不是字母时匹配，避免误匹配单词中的字母.
+func regionPattern(codes, keywords string) *regexp.Regexp {
+	return regexp.MustCompile(`(?:^|[^A-Za-z])(?:` + codes + `)(?:[^A-Za-z]|$)|` + keywords)
+}
+
+//DetectRegion 根据节点名称识别其所属地区，无法识别时返回 nil.
+func DetectRegion(name string) *Region {
+	for _, region := range Regions {
+		if region.Pattern.MatchString(name) {
+			return region
+		}
+	}
+	return nil
+}
+
+//DefaultRegionGroups 根据内置的地区识别规则生成地区分组.
+func DefaultRegionGroups() []*RegionGroup {
+	groups := make([]*RegionGroup, 0, len(Regions))
+	for _, region := range Regions {
+		groups = append(groups, &RegionGroup{
+			Name:    region.Emoji + " " + region.Name,
+			Pattern: region.Pattern.String(),
+			Type:    "url-test",
+		})
+	}
+	return groups
+}
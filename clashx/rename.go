@@ -0,0 +1,52 @@
+package clashx
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+//renameProxies 按选项重命名节点：依次执行重命名规则、插入国旗、增加前缀.
+// 重命名不会修改传入的节点，需要重命名的节点会被复制一份.
+func renameProxies(proxies []*Proxy, opts *Options) []*Proxy {
+	emoji := opts.Emoji != nil && *opts.Emoji
+	if len(opts.Rename) == 0 && opts.Prefix == "" && !emoji {
+		return proxies
+	}
+	rules := make([]*regexp.Regexp, len(opts.Rename))
+	for i, r := range opts.Rename {
+		rules[i] = regexp.MustCompile(r.Pattern)
+	}
+	renamed := make([]*Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		name := p.Name
+		for i, re := range rules {
+			name = re.ReplaceAllString(name, opts.Rename[i].Replace)
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = p.Name
+		}
+		if emoji && !hasFlagEmoji(name) {
+			if region := DetectRegion(name); region != nil {
+				name = region.Emoji + " " + name
+			}
+		}
+		name = opts.Prefix + name
+
+		if name == p.Name {
+			renamed = append(renamed, p)
+			continue
+		}
+		proxy := *p
+		proxy.Name = name
+		renamed = append(renamed, &proxy)
+	}
+	return renamed
+}
+
+//hasFlagEmoji 名称是否以国旗开头，国旗由两个区域指示符组成.
+func hasFlagEmoji(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,69 @@
+package clashx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenameProxies(t *testing.T) {
+	emoji := true
+	tests := []struct {
+		name  string
+		names []string
+		opts  *Options
+		want  []string
+	}{
+		{
+			name:  "no options",
+			names: []string{"香港 01"},
+			opts:  &Options{},
+			want:  []string{"香港 01"},
+		},
+		{
+			name:  "rename rules in order",
+			names: []string{"香港 01 | 倍率 1x", "香港 02"},
+			opts:  &Options{Rename: []*RenameRule{{Pattern: `\s*\|.*$`}, {Pattern: `香港`, Replace: "HK"}, {Pattern: `^HK (\d+)$`, Replace: "Hong Kong $1"}}},
+			want:  []string{"Hong Kong 01", "Hong Kong 02"},
+		},
+		{
+			name:  "empty result keeps the original name",
+			names: []string{"  香港 01 "},
+			opts:  &Options{Rename: []*RenameRule{{Pattern: `香港 01`}}},
+			want:  []string{"  香港 01 "},
+		},
+		{
+			name:  "emoji",
+			names: []string{"香港 01", "🇯🇵 日本 01", "Node"},
+			opts:  &Options{Emoji: &emoji},
+			want:  []string{"🇭🇰 香港 01", "🇯🇵 日本 01", "Node"},
+		},
+		{
+			name:  "rename, emoji and prefix",
+			names: []string{"HK-01"},
+			opts:  &Options{Rename: []*RenameRule{{Pattern: `-`, Replace: " "}}, Emoji: &emoji, Prefix: "[A] "},
+			want:  []string{"[A] 🇭🇰 HK 01"},
+		},
+	}
+	for _, tt := range tests {
+		var proxies []*Proxy
+		for i, name := range tt.names {
+			proxies = append(proxies, testProxy(name, 8388+i))
+		}
+		renamed := renameProxies(proxies, tt.opts)
+		var got []string
+		for i, p := range renamed {
+			got = append(got, p.Name)
+			if p.Name == tt.names[i] && p != proxies[i] {
+				t.Errorf("%s: unchanged node %q is copied", tt.name, p.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: names = %q, want %q", tt.name, got, tt.want)
+		}
+		for i, p := range proxies {
+			if p.Name != tt.names[i] {
+				t.Errorf("%s: renameProxies() modifies the input node %q", tt.name, tt.names[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package clashx
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	RuleDomain        = "DOMAIN"
+	RuleDomainSuffix  = "DOMAIN-SUFFIX"
+	RuleDomainKeyword = "DOMAIN-KEYWORD"
+	RuleGeoIP         = "GEOIP"
+	RuleIPCIDR        = "IP-CIDR"
+	RuleIPCIDR6       = "IP-CIDR6"
+	RuleSrcIPCIDR     = "SRC-IP-CIDR"
+	RuleSrcPort       = "SRC-PORT"
+	RuleDstPort       = "DST-PORT"
+	RuleProcessName   = "PROCESS-NAME"
+	RuleRuleSet       = "RULE-SET"
+	RuleMatch         = "MATCH"
+	RuleFinal         = "FINAL"
+)
+
+//ruleTypes clash 支持的规则类型，值表示该类型是否需要匹配内容.
+var ruleTypes = map[string]bool{
+	RuleDomain:        true,
+	RuleDomainSuffix:  true,
+	RuleDomainKeyword: true,
+	RuleGeoIP:         true,
+	RuleIPCIDR:        true,
+	RuleIPCIDR6:       true,
+	RuleSrcIPCIDR:     true,
+	RuleSrcPort:       true,
+	RuleDstPort:       true,
+	RuleProcessName:   true,
+	RuleRuleSet:       true,
+	RuleMatch:         false,
+	RuleFinal:         false,
+}
+
+//Rule 一条分流规则，格式为 类型,匹配内容,策略[,参数].
+type Rule struct {
+	Type    string
+	Payload string
+	Target  string
+	//附加参数，如 no-resolve
+	Params []string
+}
+
+//ParseRule 解析一条规则.
+func ParseRule(line string) (*Rule, error) {
+	fields := splitRule(line)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty rule")
+	}
+	r := &Rule{Type: strings.ToUpper(fields[0])}
+	hasPayload, ok := ruleTypes[r.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported rule type -> %s", line)
+	}
+	if !hasPayload {
+		if len(fields) != 2 || fields[1] == "" {
+			return nil, fmt.Errorf("invalid rule, expected %s,policy -> %s", r.Type, line)
+		}
+		r.Target = fields[1]
+		return r, nil
+	}
+	if len(fields) < 3 || fields[1] == "" || fields[2] == "" {
+		return nil, fmt.Errorf("invalid rule, expected type,payload,policy -> %s", line)
+	}
+	r.Payload = fields[1]
+	r.Target = fields[2]
+	r.Params = fields[3:]
+	return r, nil
+}
+
+//parsePayloadRule 解析一条不含策略的规则，格式为 类型,匹配内容[,参数]，用于规则集中的条目.
+func parsePayloadRule(line string) (*Rule, error) {
+	fields := splitRule(line)
+	if len(fields) < 2 || fields[1] == "" {
+		return nil, fmt.Errorf("invalid rule, expected type,payload -> %s", line)
+	}
+	r := &Rule{Type: strings.ToUpper(fields[0]), Payload: fields[1], Params: fields[2:]}
+	if hasPayload, ok := ruleTypes[r.Type]; !ok || !hasPayload || r.Type == RuleRuleSet {
+		return nil, fmt.Errorf("unsupported rule type -> %s", line)
+	}
+	return r, nil
+}
+
+func splitRule(line string) []string {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	fields := strings.Split(line, ",")
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+	return fields
+}
+
+//IsFinal 是否为兜底规则.
+func (r *Rule) IsFinal() bool {
+	return r.Type == RuleMatch || r.Type == RuleFinal
+}
+
+//isIPRule 是否为按目标 IP 匹配的规则，只有这类规则支持 no-resolve 参数.
+func (r *Rule) isIPRule() bool {
+	return r.Type == RuleGeoIP || r.Type == RuleIPCIDR || r.Type == RuleIPCIDR6
+}
+
+//String 输出规则的文本格式.
+func (r *Rule) String() string {
+	fields := make([]string, 0, 3+len(r.Params))
+	fields = append(fields, r.Type)
+	if r.Payload != "" {
+		fields = append(fields, r.Payload)
+	}
+	fields = append(fields, r.Target)
+	fields = append(fields, r.Params...)
+	return strings.Join(fields, ",")
+}
+
+//PayloadString 输出不含策略的规则文本，用于规则集中的条目.
+func (r *Rule) PayloadString() string {
+	fields := make([]string, 0, 2+len(r.Params))
+	fields = append(fields, r.Type, r.Payload)
+	fields = append(fields, r.Params...)
+	return strings.Join(fields, ",")
+}
+
+//ValidateCustomRules 检查自定义规则的语法是否合法，自定义规则位于模板规则之前，因此不允许使用 MATCH 规则.
+func ValidateCustomRules(lines []string) error {
+	for _, line := range lines {
+		r, err := ParseRule(line)
+		if err != nil {
+			return err
+		}
+		if r.IsFinal() {
+			return fmt.Errorf("custom rules cannot contain %s -> %s", r.Type, line)
+		}
+	}
+	return nil
+}
+
+//PrependRules 在模板规则之前插入自定义规则，规则的策略必须是已存在的节点、分组或内置策略.
+func (m *Config) PrependRules(lines []string) error {
+	if err := ValidateCustomRules(lines); err != nil {
+		return err
+	}
+	rules := make([]string, 0, len(lines)+len(m.Rule))
+	for _, line := range lines {
+		r, _ := ParseRule(line)
+		if !m.hasPolicy(r.Target) {
+			return fmt.Errorf("rule references unknown proxy or group -> %s", line)
+		}
+		if _, ok := m.RuleProviders[r.Payload]; r.Type == RuleRuleSet && !ok {
+			return fmt.Errorf("rule references unknown rule provider -> %s", line)
+		}
+		rules = append(rules, r.String())
+	}
+	m.Rule = append(rules, m.Rule...)
+	return nil
+}
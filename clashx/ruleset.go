@@ -0,0 +1,352 @@
+package clashx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	BehaviorClassical = "classical"
+	BehaviorDomain    = "domain"
+	BehaviorIPCIDR    = "ipcidr"
+
+	//clash rule-provider 的 yaml 格式
+	FormatClash = "clash"
+	//surge 的 .list 规则列表，或每行一个域名的 domain-set
+	FormatSurge = "surge"
+	//quantumult x 的 filter 规则列表
+	FormatQuanX = "quanx"
+	//base64 编码或明文的 GFWList（AutoProxy 语法）
+	FormatGFWList = "gfwlist"
+	//AdGuard / Adblock 格式的域名列表
+	FormatAdblock = "adblock"
+	//hosts 格式的屏蔽列表
+	FormatHosts = "hosts"
+
+	//规则集默认更新间隔（秒）
+	defaultRuleSetInterval = 86400
+
+	//规则列表中例外规则（如 GFWList 和 Adblock 的 @@ 规则）的策略，这类条目带有策略，需要放在规则集之前
+	exceptionPolicy = "DIRECT"
+)
+
+//RuleFormat 将规则列表解析为不含策略的规则条目，如 DOMAIN-SUFFIX,google.com，例外规则以带有 DIRECT 策略的条目表示.
+type RuleFormat func(body []byte, behavior string) ([]string, error)
+
+var (
+	//内置的格式在声明时注册，保证模板在包初始化时解析 rule-providers 可以使用这些格式
+	ruleFormats = map[string]RuleFormat{
+		FormatClash:   parseClashRuleSet,
+		FormatSurge:   parseSurgeRuleSet,
+		FormatQuanX:   parseQuanXRuleSet,
+		FormatGFWList: parseGFWList,
+		FormatAdblock: parseAdblock,
+		FormatHosts:   parseHosts,
+	}
+	ruleFormatLock = &sync.RWMutex{}
+)
+
+//RegisterRuleFormat 注册一种规则列表格式.
+func RegisterRuleFormat(name string, f RuleFormat) {
+	ruleFormatLock.Lock()
+	defer ruleFormatLock.Unlock()
+	ruleFormats[name] = f
+}
+
+//GetRuleFormat 获取指定名称的规则列表格式，名称为空时返回 clash 格式.
+func GetRuleFormat(name string) RuleFormat {
+	if name == "" {
+		name = FormatClash
+	}
+	ruleFormatLock.RLock()
+	defer ruleFormatLock.RUnlock()
+	return ruleFormats[name]
+}
+
+//RuleProvider 规则集.
+type RuleProvider struct {
+	//http / file
+	Type string `yaml:"type" json:"type"`
+	//classical / domain / ipcidr
+	Behavior string `yaml:"behavior" json:"behavior"`
+	//规则集地址，也可以使用 geosite:分类 / geoip:分类 引用本服务加载的 V2Ray 数据文件
+	URL  string `yaml:"url,omitempty" json:"url,omitempty"`
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	//更新间隔（秒）
+	Interval int `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	//以下字段仅供本服务使用，不会输出到配置文件中
+	//规则列表格式：clash / surge / quanx / gfwlist / adblock / hosts，默认为 clash，非 clash 格式的规则集由本服务转换后提供
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+	//由本服务拉取规则列表后展开为普通规则
+	Inline bool `yaml:"inline,omitempty" json:"inline,omitempty"`
+}
+
+//MarshalYAML 输出时去掉仅供本服务使用的字段.
+func (m *RuleProvider) MarshalYAML() (interface{}, error) {
+	return struct {
+		Type     string `yaml:"type"`
+		Behavior string `yaml:"behavior"`
+		URL      string `yaml:"url,omitempty"`
+		Path     string `yaml:"path,omitempty"`
+		Interval int    `yaml:"interval,omitempty"`
+	}{m.Type, m.Behavior, m.URL, m.Path, m.Interval}, nil
+}
+
+//NeedInline 规则集是否需要由本服务展开.
+func (m *RuleProvider) NeedInline() bool {
+	return m.Inline
+}
+
+//RefreshInterval 规则集的更新间隔（秒）.
+func (m *RuleProvider) RefreshInterval() int {
+	if m.Interval > 0 {
+		return m.Interval
+	}
+	return defaultRuleSetInterval
+}
+
+func (m *RuleProvider) validate(name string) error {
+	switch m.Behavior {
+	case BehaviorClassical, BehaviorDomain, BehaviorIPCIDR:
+	default:
+		return fmt.Errorf("invalid rule provider behavior -> %s %s", name, m.Behavior)
+	}
+	if GetRuleFormat(m.Format) == nil {
+		return fmt.Errorf("unsupported rule provider format -> %s %s", name, m.Format)
+	}
+	if m.URL == "" && m.Path == "" {
+		return fmt.Errorf("rule provider url and path are empty -> %s", name)
+	}
+	return nil
+}
+
+//ParseRuleSet 按格式解析规则列表，返回不含策略的规则条目.
+func ParseRuleSet(body []byte, format, behavior string) ([]string, error) {
+	f := GetRuleFormat(format)
+	if f == nil {
+		return nil, fmt.Errorf("unsupported rule format -> %s", format)
+	}
+	return f(body, behavior)
+}
+
+//SplitExceptions 将规则集条目分为不含策略的普通条目和带有 DIRECT 策略的例外规则.
+func SplitExceptions(payload []string) (items, exceptions []string) {
+	for _, item := range payload {
+		if isException(item) {
+			exceptions = append(exceptions, item)
+		} else {
+			items = append(items, item)
+		}
+	}
+	return items, exceptions
+}
+
+func isException(item string) bool {
+	return strings.HasSuffix(item, ","+exceptionPolicy)
+}
+
+//exceptionRules 将规则集中的例外规则转换为规则，params 为引用规则集的 RULE-SET 规则的参数.
+func exceptionRules(payload []string, params []string) []string {
+	var rules []string
+	for _, item := range payload {
+		if !isException(item) {
+			continue
+		}
+		r, err := ParseRule(item)
+		if err != nil {
+			continue
+		}
+		if r.isIPRule() {
+			r.Params = mergeParams(r.Params, params)
+		}
+		rules = append(rules, r.String())
+	}
+	return rules
+}
+
+//RuleSetLoader 获取规则集的内容.
+type RuleSetLoader func(name string, provider *RuleProvider) ([]string, error)
+
+//InlineRuleSets 将引用了需要展开的规则集的 RULE-SET 规则展开为普通规则，
+//all 为 true 时展开全部规则集. 已展开的规则集会从 rule-providers 中移除.
+// 由本服务转换格式的规则集中的例外规则无法放入规则集，不展开时也会作为普通规则插入到 RULE-SET 规则之前.
+func (m *Config) InlineRuleSets(load RuleSetLoader, all bool) {
+	if len(m.RuleProviders) == 0 {
+		return
+	}
+	inlined := make(map[string][]string)
+	rules := make([]string, 0, len(m.Rule))
+	for _, line := range m.Rule {
+		r, err := ParseRule(line)
+		if err != nil || r.Type != RuleRuleSet {
+			rules = append(rules, line)
+			continue
+		}
+		provider, ok := m.RuleProviders[r.Payload]
+		if !ok {
+			rules = append(rules, line)
+			continue
+		}
+		if !(all || provider.NeedInline()) {
+			if provider.Format != "" && provider.Format != FormatClash {
+				if payload, err := load(r.Payload, provider); err == nil {
+					rules = append(rules, exceptionRules(payload, r.Params)...)
+				}
+			}
+			rules = append(rules, line)
+			continue
+		}
+		payload, ok := inlined[r.Payload]
+		if !ok {
+			if payload, err = load(r.Payload, provider); err != nil {
+				m.Warnings = append(m.Warnings, fmt.Sprintf("failed to load rule set %s, rule removed -> %s", r.Payload, err))
+			}
+			inlined[r.Payload] = payload
+		}
+		rules = append(rules, exceptionRules(payload, r.Params)...)
+		for _, item := range payload {
+			if isException(item) {
+				continue
+			}
+			pr, err := parsePayloadRule(item)
+			if err != nil {
+				continue
+			}
+			pr.Target = r.Target
+			if pr.isIPRule() {
+				pr.Params = mergeParams(pr.Params, r.Params)
+			}
+			rules = append(rules, pr.String())
+		}
+	}
+	m.Rule = rules
+	for name := range inlined {
+		delete(m.RuleProviders, name)
+	}
+}
+
+func mergeParams(a, b []string) []string {
+	params := append([]string(nil), a...)
+	for _, p := range b {
+		exists := false
+		for _, q := range params {
+			if p == q {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+//payloadFromEntry 将 domain / ipcidr 类型规则集中的条目转换为规则条目.
+func payloadFromEntry(entry, behavior string) (string, bool) {
+	entry = strings.Trim(strings.TrimSpace(entry), `'"`)
+	if entry == "" {
+		return "", false
+	}
+	switch behavior {
+	case BehaviorDomain:
+		switch {
+		case strings.HasPrefix(entry, "+."):
+			return RuleDomainSuffix + "," + entry[2:], true
+		case strings.HasPrefix(entry, "*."):
+			return RuleDomainSuffix + "," + entry[2:], true
+		case strings.HasPrefix(entry, "."):
+			return RuleDomainSuffix + "," + entry[1:], true
+		case strings.Contains(entry, "*"):
+			return "", false
+		}
+		return RuleDomain + "," + entry, true
+	case BehaviorIPCIDR:
+		ip, _, err := net.ParseCIDR(entry)
+		if err != nil {
+			return "", false
+		}
+		if ip.To4() == nil {
+			return RuleIPCIDR6 + "," + entry, true
+		}
+		return RuleIPCIDR + "," + entry, true
+	}
+	r, err := parsePayloadRule(entry)
+	if err != nil {
+		return "", false
+	}
+	return r.PayloadString(), true
+}
+
+//parseClashRuleSet 解析 clash rule-provider 的 yaml 格式.
+func parseClashRuleSet(body []byte, behavior string) ([]string, error) {
+	var data struct {
+		Payload []string `yaml:"payload"`
+	}
+	if err := yaml.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	payload := make([]string, 0, len(data.Payload))
+	for _, entry := range data.Payload {
+		if item, ok := payloadFromEntry(entry, behavior); ok {
+			payload = append(payload, item)
+		}
+	}
+	return payload, nil
+}
+
+//parseSurgeRuleSet 解析 surge 的 .list 规则列表或 domain-set.
+func parseSurgeRuleSet(body []byte, behavior string) ([]string, error) {
+	var payload []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if item, ok := payloadFromEntry(line, behavior); ok {
+			payload = append(payload, item)
+		}
+	}
+	return payload, scanner.Err()
+}
+
+//quanXRuleTypes quantumult x 规则类型与 clash 规则类型的对应关系.
+var quanXRuleTypes = map[string]string{
+	"host":         RuleDomain,
+	"host-suffix":  RuleDomainSuffix,
+	"host-keyword": RuleDomainKeyword,
+	"ip-cidr":      RuleIPCIDR,
+	"ip6-cidr":     RuleIPCIDR6,
+	"geoip":        RuleGeoIP,
+}
+
+//parseQuanXRuleSet 解析 quantumult x 的 filter 规则列表，规则中的策略会被忽略.
+func parseQuanXRuleSet(body []byte, behavior string) ([]string, error) {
+	var payload []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		fields := splitRule(line)
+		if len(fields) < 2 {
+			continue
+		}
+		t, ok := quanXRuleTypes[strings.ToLower(fields[0])]
+		if !ok {
+			continue
+		}
+		payload = append(payload, t+","+fields[1])
+	}
+	return payload, scanner.Err()
+}
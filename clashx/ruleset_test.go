@@ -0,0 +1,168 @@
+package clashx
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+const testGFWList = `[AutoProxy 0.2.9]
+! Checksum: test
+||google.com
+|https://www.twitter.com/path
+.youtube.com
+||mail.google.com
+example.org
+keyword
+/^https?:\/\/[^\/]+blogspot\.(.*)/
+||1.2.3.4
+@@||cn.google.com
+@@|http://unrelated.net
+@@/^https?:\/\/[^\/]+example\.org/
+`
+
+const testAdblock = `! Title: test
+[Adblock Plus 2.0]
+||ads.example.com^
+||sub.ads.example.com^
+||tracker.example.net^$important
+||cdn.example.com^$third-party
+/banner/*
+example.org##.ad
+plain.example.com
+@@||safe.ads.example.com^
+@@||unrelated.com^
+@@||ads.example.com/path
+`
+
+const testHosts = `# comment
+127.0.0.1 localhost
+::1 ip6-localhost ip6-loopback
+0.0.0.0 0.0.0.0
+0.0.0.0 ads.example.com tracker.example.com # trailing comment
+0.0.0.0 ADS.example.com.
+plain.example.org
+invalid line here
+`
+
+func TestParseRuleSet(t *testing.T) {
+	gfwlist := []string{
+		"DOMAIN-SUFFIX,cn.google.com,DIRECT",
+		"DOMAIN-SUFFIX,google.com",
+		"DOMAIN,www.twitter.com",
+		"DOMAIN-SUFFIX,youtube.com",
+		"DOMAIN-SUFFIX,example.org",
+		"DOMAIN-KEYWORD,keyword",
+		"IP-CIDR,1.2.3.4/32",
+	}
+	tests := []struct {
+		name     string
+		body     string
+		format   string
+		behavior string
+		want     []string
+	}{
+		{name: "gfwlist", body: testGFWList, format: FormatGFWList, behavior: BehaviorClassical, want: gfwlist},
+		{name: "gfwlist base64", body: base64.StdEncoding.EncodeToString([]byte(testGFWList)), format: FormatGFWList, behavior: BehaviorClassical, want: gfwlist},
+		{name: "gfwlist exact exception keeps suffix", body: "||example.com\n@@|http://example.com", format: FormatGFWList, behavior: BehaviorClassical, want: []string{
+			"DOMAIN,example.com,DIRECT",
+			"DOMAIN-SUFFIX,example.com",
+		}},
+		{name: "gfwlist suffix exception", body: "||example.com\n||a.example.com\n|http://b.example.com\n@@||a.example.com", format: FormatGFWList, behavior: BehaviorClassical, want: []string{
+			"DOMAIN-SUFFIX,a.example.com,DIRECT",
+			"DOMAIN-SUFFIX,example.com",
+		}},
+		{name: "gfwlist exception covers whole entries", body: "||example.com\n|http://www.example.com\n@@||example.com", format: FormatGFWList, behavior: BehaviorClassical, want: []string{
+			"DOMAIN-SUFFIX,example.com,DIRECT",
+		}},
+		{name: "adblock", body: testAdblock, format: FormatAdblock, behavior: BehaviorClassical, want: []string{
+			"DOMAIN-SUFFIX,safe.ads.example.com,DIRECT",
+			"DOMAIN-SUFFIX,ads.example.com",
+			"DOMAIN-SUFFIX,tracker.example.net",
+			"DOMAIN,plain.example.com",
+		}},
+		{name: "adblock exact exception", body: "||example.com^\n||www.example.com^\n@@||www.example.com^", format: FormatAdblock, behavior: BehaviorClassical, want: []string{
+			"DOMAIN-SUFFIX,www.example.com,DIRECT",
+			"DOMAIN-SUFFIX,example.com",
+		}},
+		{name: "adblock exact exception keeps suffix", body: "||example.com^\n@@|https://example.com^", format: FormatAdblock, behavior: BehaviorClassical, want: []string{
+			"DOMAIN,example.com,DIRECT",
+			"DOMAIN-SUFFIX,example.com",
+		}},
+		{name: "adblock exact rules", body: "|http://ads.example.com^\n|tracker.example.com|\n@@|http://ads.example.com^", format: FormatAdblock, behavior: BehaviorClassical, want: []string{
+			"DOMAIN,ads.example.com,DIRECT",
+			"DOMAIN,tracker.example.com",
+		}},
+		{name: "adblock suffix exception", body: "||example.com^\n|http://www.example.com^\n||cdn.example.com^\n@@||cdn.example.com^\n@@||unrelated.org^", format: FormatAdblock, behavior: BehaviorClassical, want: []string{
+			"DOMAIN-SUFFIX,cdn.example.com,DIRECT",
+			"DOMAIN-SUFFIX,example.com",
+		}},
+		{name: "hosts", body: testHosts, format: FormatHosts, behavior: BehaviorClassical, want: []string{
+			"DOMAIN,ads.example.com",
+			"DOMAIN,tracker.example.com",
+			"DOMAIN,plain.example.org",
+		}},
+		{name: "surge domain-set", body: "# comment\n.google.com\nmail.google.com\n*.wildcard.com\na*b.com", format: FormatSurge, behavior: BehaviorDomain, want: []string{
+			"DOMAIN-SUFFIX,google.com",
+			"DOMAIN,mail.google.com",
+			"DOMAIN-SUFFIX,wildcard.com",
+		}},
+		{name: "quanx", body: "host-suffix, google.com, proxy\nip-cidr, 10.0.0.0/8, direct\nuser-agent, foo*, reject", format: FormatQuanX, behavior: BehaviorClassical, want: []string{
+			"DOMAIN-SUFFIX,google.com",
+			"IP-CIDR,10.0.0.0/8",
+		}},
+		{name: "clash ipcidr", body: "payload:\n  - '10.0.0.0/8'\n  - 'fc00::/7'\n  - 'invalid'", format: FormatClash, behavior: BehaviorIPCIDR, want: []string{
+			"IP-CIDR,10.0.0.0/8",
+			"IP-CIDR6,fc00::/7",
+		}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRuleSet([]byte(tt.body), tt.format, tt.behavior)
+		if err != nil {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if _, err := ParseRuleSet(nil, "unknown", BehaviorClassical); err == nil {
+		t.Errorf("ParseRuleSet() with an unknown format should fail")
+	}
+}
+
+func TestInlineRuleSetsExceptions(t *testing.T) {
+	payloads := map[string]string{
+		"ads": testAdblock,
+		"gfw": testGFWList,
+	}
+	load := func(name string, provider *RuleProvider) ([]string, error) {
+		return ParseRuleSet([]byte(payloads[name]), provider.Format, provider.Behavior)
+	}
+	c := &Config{
+		RuleProviders: map[string]*RuleProvider{
+			"ads": {Type: "http", Behavior: BehaviorClassical, Format: FormatAdblock, URL: "https://example.com/ads.txt", Inline: true},
+			"gfw": {Type: "http", Behavior: BehaviorClassical, Format: FormatGFWList, URL: "https://example.com/gfw.txt"},
+		},
+		Rule: []string{"RULE-SET,ads,REJECT", "RULE-SET,gfw,Proxy", "MATCH,DIRECT"},
+	}
+	c.InlineRuleSets(load, false)
+	want := []string{
+		"DOMAIN-SUFFIX,safe.ads.example.com,DIRECT",
+		"DOMAIN-SUFFIX,ads.example.com,REJECT",
+		"DOMAIN-SUFFIX,tracker.example.net,REJECT",
+		"DOMAIN,plain.example.com,REJECT",
+		"DOMAIN-SUFFIX,cn.google.com,DIRECT",
+		"RULE-SET,gfw,Proxy",
+		"MATCH,DIRECT",
+	}
+	if !reflect.DeepEqual(c.Rule, want) {
+		t.Errorf("rules = %q, want %q", c.Rule, want)
+	}
+	if _, ok := c.RuleProviders["ads"]; ok {
+		t.Errorf("inlined rule provider is not removed")
+	}
+	if _, ok := c.RuleProviders["gfw"]; !ok {
+		t.Errorf("rule provider that is not inlined is removed")
+	}
+}
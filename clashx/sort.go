@@ -0,0 +1,129 @@
+package clashx
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	//保持订阅中的顺序
+	SortSource = "source"
+	//按名称排序，名称中的数字按数值比较
+	SortName = "name"
+	//按识别到的地区排序
+	SortRegion = "region"
+	//按名称中的倍率排序
+	SortMultiplier = "multiplier"
+)
+
+//multiplierPattern 识别名称中的倍率，如 x0.5、0.5x、×2、2倍、倍率:1.5，x 在前时前面不能是字母，在后时后面不能是字母或数字，以免把 Netflix 3、Xbox 等识别为倍率
+var multiplierPattern = regexp.MustCompile(`(?i)(?:(?:^|[^A-Za-z])[x×]|倍率[:：]?)\s*(\d+(?:\.\d+)?)|(\d+(?:\.\d+)?)\s*(?:[x×](?:[^A-Za-z\d.]|$)|倍)`)
+
+//sortProxies 按指定方式对节点进行稳定排序.
+// 按地区排序时使用 regionOrder 中的地区代码或名称的顺序，为空时使用内置地区的顺序，未识别的地区排在最后.
+func sortProxies(proxies []*Proxy, by string, regionOrder []string) []*Proxy {
+	if by == "" || by == SortSource || len(proxies) < 2 {
+		return proxies
+	}
+	sorted := append([]*Proxy(nil), proxies...)
+	switch by {
+	case SortName:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return naturalLess(sorted[i].Name, sorted[j].Name)
+		})
+	case SortRegion:
+		rank := regionRank(regionOrder)
+		keys := make(map[*Proxy]int, len(sorted))
+		for _, p := range sorted {
+			keys[p] = len(rank)
+			if region := DetectRegion(p.Name); region != nil {
+				if r, ok := rank[region.Code]; ok {
+					keys[p] = r
+				}
+			}
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return keys[sorted[i]] < keys[sorted[j]]
+		})
+	case SortMultiplier:
+		keys := make(map[*Proxy]float64, len(sorted))
+		for _, p := range sorted {
+			keys[p] = parseMultiplier(p.Name)
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return keys[sorted[i]] < keys[sorted[j]]
+		})
+	}
+	return sorted
+}
+
+//regionRank 返回地区代码到排序位置的映射.
+func regionRank(order []string) map[string]int {
+	rank := make(map[string]int, len(Regions))
+	if len(order) == 0 {
+		for i, region := range Regions {
+			rank[region.Code] = i
+		}
+		return rank
+	}
+	for i, item := range order {
+		for _, region := range Regions {
+			if strings.EqualFold(item, region.Code) || item == region.Name {
+				if _, ok := rank[region.Code]; !ok {
+					rank[region.Code] = i
+				}
+			}
+		}
+	}
+	return rank
+}
+
+//parseMultiplier 解析名称中的倍率，没有倍率时返回 1.
+func parseMultiplier(name string) float64 {
+	if m := multiplierPattern.FindStringSubmatch(name); m != nil {
+		v := m[1]
+		if v == "" {
+			v = m[2]
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return 1
+}
+
+//naturalLess 按自然顺序比较两个字符串，其中的连续数字按数值比较.
+func naturalLess(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			si := i
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			sj := j
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		if ra[i] != rb[j] {
+			return ra[i] < rb[j]
+		}
+		i++
+		j++
+	}
+	return len(ra)-i < len(rb)-j
+}
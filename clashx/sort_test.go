@@ -0,0 +1,84 @@
+package clashx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMultiplier(t *testing.T) {
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{name: "香港 01 x0.5", want: 0.5},
+		{name: "香港 01 0.5x", want: 0.5},
+		{name: "日本 ×2", want: 2},
+		{name: "美国 2倍", want: 2},
+		{name: "倍率:1.5 台湾", want: 1.5},
+		{name: "IPLC-X3", want: 3},
+		{name: "[x10] 专线", want: 10},
+		{name: "香港 3X", want: 3},
+		{name: "香港 01", want: 1},
+		{name: "新加坡 Netflix 3", want: 1},
+		{name: "HK Box 5", want: 1},
+		{name: "香港 2 Xbox", want: 1},
+	}
+	for _, tt := range tests {
+		if got := parseMultiplier(tt.name); got != tt.want {
+			t.Errorf("parseMultiplier(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "香港 2", b: "香港 10", want: true},
+		{a: "香港 10", b: "香港 2", want: false},
+		{a: "香港 02", b: "香港 10", want: true},
+		{a: "a01", b: "a1", want: false},
+		{a: "a1", b: "a01", want: false},
+		{a: "a", b: "a1", want: true},
+		{a: "a9", b: "b1", want: true},
+		{a: "节点 9 x", b: "节点 9 y", want: true},
+		{a: "节点", b: "节点", want: false},
+	}
+	for _, tt := range tests {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortProxies(t *testing.T) {
+	names := []string{"美国 10 x2", "香港 2", "Unknown", "日本 1 x0.5", "香港 10 Netflix 3", "日本 3"}
+	tests := []struct {
+		by    string
+		order []string
+		want  []string
+	}{
+		{by: "", want: names},
+		{by: SortSource, want: names},
+		{by: SortName, want: []string{"Unknown", "日本 1 x0.5", "日本 3", "美国 10 x2", "香港 2", "香港 10 Netflix 3"}},
+		{by: SortMultiplier, want: []string{"日本 1 x0.5", "香港 2", "Unknown", "香港 10 Netflix 3", "日本 3", "美国 10 x2"}},
+		{by: SortRegion, order: []string{"JP", "香港"}, want: []string{"日本 1 x0.5", "日本 3", "香港 2", "香港 10 Netflix 3", "美国 10 x2", "Unknown"}},
+	}
+	for _, tt := range tests {
+		var proxies []*Proxy
+		for _, name := range names {
+			proxies = append(proxies, &Proxy{Name: name})
+		}
+		var got []string
+		for _, p := range sortProxies(proxies, tt.by, tt.order) {
+			got = append(got, p.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sortProxies(%q) = %q, want %q", tt.by, got, tt.want)
+		}
+		if proxies[0].Name != names[0] {
+			t.Errorf("sortProxies(%q) modifies the input", tt.by)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package clashx
+
+import (
+	"fmt"
+	"regexp"
+)
+
+//Source 托管配置的一个订阅来源，多个来源的节点会合并到同一份配置中.
+type Source struct {
+	//来源名称，开启来源分组时作为分组名称
+	Name string `yaml:"name" json:"name"`
+	URL  string `yaml:"url" json:"url"`
+	//同一订阅的备用地址，URL 拉取失败时按顺序尝试
+	Mirrors []string `yaml:"mirrors,omitempty" json:"mirrors,omitempty"`
+	//订阅格式，默认为 vmess
+	Converter string `yaml:"converter,omitempty" json:"converter,omitempty"`
+	//只保留名称匹配该正则的节点
+	Include string `yaml:"include,omitempty" json:"include,omitempty"`
+	//排除名称匹配该正则的节点
+	Exclude string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+	//该来源节点名称的前缀
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+}
+
+//Validate 检查来源的配置是否合法.
+func (s *Source) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("source name is empty -> %s", s.URL)
+	}
+	if s.URL == "" {
+		return fmt.Errorf("source url is empty -> %s", s.Name)
+	}
+	for _, mirror := range s.Mirrors {
+		if mirror == "" {
+			return fmt.Errorf("source mirror url is empty -> %s", s.Name)
+		}
+	}
+	if GetConverter(s.ConverterName()) == nil {
+		return fmt.Errorf("converter does not exist -> %s %s", s.Name, s.Converter)
+	}
+	if _, err := regexp.Compile(s.Include); err != nil {
+		return fmt.Errorf("invalid source include pattern -> %s %s", s.Name, err)
+	}
+	if _, err := regexp.Compile(s.Exclude); err != nil {
+		return fmt.Errorf("invalid source exclude pattern -> %s %s", s.Name, err)
+	}
+	return nil
+}
+
+//ConverterName 来源使用的订阅格式.
+func (s *Source) ConverterName() string {
+	if s.Converter == "" {
+		return "vmess"
+	}
+	return s.Converter
+}
+
+//URLs 来源的全部地址，首个为 URL，其后为备用地址.
+func (s *Source) URLs() []string {
+	return append([]string{s.URL}, s.Mirrors...)
+}
+
+//Apply 按来源的过滤条件和前缀处理节点，并记录节点所属的来源，传入的节点不会被修改.
+func (s *Source) Apply(proxies []*Proxy) []*Proxy {
+	proxies = filterProxies(proxies, s.Include, s.Exclude)
+	result := make([]*Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		proxy := *p
+		proxy.Name = s.Prefix + p.Name
+		proxy.source = s.Name
+		result = append(result, &proxy)
+	}
+	return result
+}
+
+//buildSourceGroups 为每个来源生成一个 select 分组，没有节点的来源会被忽略.
+func buildSourceGroups(proxies []*Proxy) []*ProxyGroup {
+	var groups []*ProxyGroup
+	index := make(map[string]*ProxyGroup)
+	for _, p := range proxies {
+		if p.source == "" {
+			continue
+		}
+		g, ok := index[p.source]
+		if !ok {
+			g = &ProxyGroup{Name: p.source, Type: GroupSelect, Proxies: make([]string, 0)}
+			index[p.source] = g
+			groups = append(groups, g)
+		}
+		g.addProxy(p.Name)
+	}
+	return groups
+}
@@ -0,0 +1,331 @@
+package clashx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	//完整的国内外分流规则模板
+	TemplateFull = "full"
+	//只包含局域网和国内直连规则的精简模板
+	TemplateMinimal = "minimal"
+	//全局代理模板
+	TemplateGlobal = "global"
+)
+
+//DefaultTemplate 内置的默认配置模板.
+var DefaultTemplate = MustParseTemplate(strings.NewReader(ConfigStr))
+
+//dynamicKeys 值为字典的字段，输出时以配置中的内容为准，不保留模板中多余的键.
+var dynamicKeys = map[string]bool{
+	"rule-providers": true,
+	"hosts":          true,
+}
+
+//modernKeys 新版 clash 使用的字段名与本程序使用的字段名的对应关系.
+var modernKeys = map[string]string{
+	"proxies":      "Proxy",
+	"proxy-groups": "Proxy Group",
+	"rules":        "Rule",
+}
+
+var (
+	templates    = make(map[string]*Template)
+	templateLock = &sync.RWMutex{}
+)
+
+//Template 配置模板.
+// 模板会保留原始的 yaml 节点树，生成配置时只改写 Config 中建模的字段，
+// 模板中的其他字段（如 dns、experimental、hosts 等）、顺序以及注释都会原样输出.
+type Template struct {
+	node   *yaml.Node
+	config *Config
+}
+
+//ParseTemplate 解析一个配置模板.
+func ParseTemplate(r io.Reader) (*Template, error) {
+	node := &yaml.Node{}
+	if err := yaml.NewDecoder(r).Decode(node); err != nil {
+		return nil, err
+	}
+	if node.Kind != yaml.DocumentNode || len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("the template must be a yaml mapping")
+	}
+	keys, err := normalizeKeys(node.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{keys: keys}
+	if err := node.Decode(c); err != nil {
+		return nil, err
+	}
+	for _, g := range c.ProxyGroup {
+		if err := g.validate(); err != nil {
+			return nil, err
+		}
+	}
+	for name, p := range c.RuleProviders {
+		if err := p.validate(name); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.ProxyGroup) == 0 {
+		c.ProxyGroup = make([]*ProxyGroup, 1)
+		c.ProxyGroup[0] = &ProxyGroup{
+			Name:    "Proxy",
+			Type:    GroupSelect,
+			Proxies: make([]string, 0),
+		}
+	}
+	c.node = node
+	return &Template{node: node, config: c}, nil
+}
+
+//normalizeKeys 将模板中新版 clash 的字段名（proxies、proxy-groups、rules）改为本程序使用的字段名，
+// 返回被改写的字段名，输出时再改回原来的名称. 同一字段同时使用新旧两种名称时返回错误.
+func normalizeKeys(node *yaml.Node) (map[string]string, error) {
+	present := make(map[string]bool, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		present[node.Content[i].Value] = true
+	}
+	var keys map[string]string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		legacy, ok := modernKeys[key.Value]
+		if !ok {
+			continue
+		}
+		if present[legacy] {
+			return nil, fmt.Errorf("the template uses both %s and %s", key.Value, legacy)
+		}
+		if keys == nil {
+			keys = make(map[string]string)
+		}
+		keys[legacy] = key.Value
+		key.Value = legacy
+	}
+	return keys, nil
+}
+
+//MustParseTemplate 解析一个配置模板，解析失败时 panic.
+func MustParseTemplate(r io.Reader) *Template {
+	t, err := ParseTemplate(r)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+//RegisterTemplate 注册一个模板，已存在同名模板时替换.
+func RegisterTemplate(name string, t *Template) {
+	templateLock.Lock()
+	defer templateLock.Unlock()
+	templates[name] = t
+}
+
+//GetTemplate 获取指定名称的模板，名称为空时返回默认模板，模板不存在时返回 nil.
+func GetTemplate(name string) *Template {
+	if name == "" {
+		return DefaultTemplate
+	}
+	templateLock.RLock()
+	defer templateLock.RUnlock()
+	return templates[name]
+}
+
+//TemplateNames 返回已注册的模板名称.
+func TemplateNames() []string {
+	templateLock.RLock()
+	defer templateLock.RUnlock()
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//LoadTemplates 加载目录中的 .yaml 和 .yml 模板，模板名称为去掉扩展名后的文件名.
+func LoadTemplates(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		t, err := ParseTemplate(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("failed to parse template %s -> %s", f.Name(), err)
+		}
+		RegisterTemplate(strings.TrimSuffix(f.Name(), ext), t)
+	}
+	return nil
+}
+
+//Config 返回一个基于模板的配置副本，对副本的修改不会影响模板.
+func (t *Template) Config() *Config {
+	return t.config.Clone()
+}
+
+//marshal 将配置合并到模板节点树中后输出.
+func (m *Config) marshal() ([]byte, error) {
+	if m.node == nil {
+		return yaml.Marshal(m)
+	}
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	src := &yaml.Node{}
+	if err := yaml.Unmarshal(b, src); err != nil {
+		return nil, err
+	}
+	doc := *m.node
+	out := mergeNode(m.node.Content[0], src.Content[0], false)
+	if len(m.keys) > 0 {
+		//改回模板中使用的字段名，合并结果与模板共享键节点，需要复制后再修改
+		content := make([]*yaml.Node, len(out.Content))
+		copy(content, out.Content)
+		for i := 0; i+1 < len(content); i += 2 {
+			if name, ok := m.keys[content[i].Value]; ok {
+				key := *content[i]
+				key.Value = name
+				content[i] = &key
+			}
+		}
+		out.Content = content
+	}
+	doc.Content = []*yaml.Node{out}
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//mergeNode 将 src 合并到 dst 上，返回合并后的新节点，dst 本身不会被修改.
+// 值未发生变化的节点会原样保留，包括其注释和格式.
+//exact 为 true 时 dst 中存在而 src 中不存在的键会被移除.
+func mergeNode(dst, src *yaml.Node, exact bool) *yaml.Node {
+	if dst == nil {
+		return src
+	}
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		out := *dst
+		out.Content = make([]*yaml.Node, 0, len(dst.Content)+len(src.Content))
+
+		index := make(map[string]int, len(src.Content)/2)
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			index[src.Content[i].Value] = i
+		}
+		merged := make(map[string]bool, len(src.Content)/2)
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			key, value := dst.Content[i], dst.Content[i+1]
+			if j, ok := index[key.Value]; ok {
+				out.Content = append(out.Content, key, mergeNode(value, src.Content[j+1], exact || dynamicKeys[key.Value]))
+				merged[key.Value] = true
+			} else if !exact && !dynamicKeys[key.Value] {
+				out.Content = append(out.Content, key, value)
+			}
+		}
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			if !merged[key.Value] && !isZeroNode(value) {
+				out.Content = append(out.Content, key, value)
+			}
+		}
+		return &out
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		out := *dst
+		out.Content = make([]*yaml.Node, 0, len(src.Content))
+
+		//相同的元素复用模板中的节点，以便保留其注释
+		unused := make(map[string][]*yaml.Node, len(dst.Content))
+		for _, item := range dst.Content {
+			key := nodeKey(item)
+			unused[key] = append(unused[key], item)
+		}
+		for _, item := range src.Content {
+			key := nodeKey(item)
+			if nodes := unused[key]; len(nodes) > 0 {
+				out.Content = append(out.Content, nodes[0])
+				unused[key] = nodes[1:]
+			} else {
+				out.Content = append(out.Content, item)
+			}
+		}
+		return &out
+	}
+	if nodeKey(dst) == nodeKey(src) {
+		return dst
+	}
+	out := *src
+	out.HeadComment = dst.HeadComment
+	out.LineComment = dst.LineComment
+	out.FootComment = dst.FootComment
+	return &out
+}
+
+//nodeKey 返回节点值的规范化表示，用于比较两个节点的值是否相同.
+func nodeKey(n *yaml.Node) string {
+	if n.Kind == yaml.ScalarNode {
+		return n.ShortTag() + ":" + n.Value
+	}
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return ""
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func isZeroNode(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!null":
+			return true
+		case "!!str":
+			return n.Value == ""
+		case "!!int", "!!float":
+			return n.Value == "0"
+		case "!!bool":
+			return n.Value == "false"
+		}
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(n.Content) == 0
+	}
+	return false
+}
+
+func init() {
+	RegisterTemplate(TemplateFull, DefaultTemplate)
+	RegisterTemplate(TemplateMinimal, MustParseTemplate(strings.NewReader(MinimalConfigStr)))
+	RegisterTemplate(TemplateGlobal, MustParseTemplate(strings.NewReader(GlobalConfigStr)))
+}
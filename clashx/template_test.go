@@ -0,0 +1,110 @@
+package clashx
+
+import (
+	"gopkg.in/yaml.v3"
+	"strings"
+	"testing"
+)
+
+const testTemplate = `# 模板说明
+port: 7890
+socks-port: 7891
+allow-lan: false
+mode: Rule
+log-level: info
+external-controller: 127.0.0.1:9090
+dns:
+  enable: true # 开启 DNS
+  nameserver:
+    - 223.5.5.5
+hosts:
+  router.local: 192.168.1.1
+Proxy: []
+Proxy Group:
+  - name: Proxy
+    type: select
+    proxies: []
+Rule:
+  # 局域网
+  - DOMAIN-SUFFIX,local,DIRECT
+  - MATCH,Proxy
+`
+
+func TestTemplateRoundTrip(t *testing.T) {
+	modern := strings.NewReplacer("Proxy: []", "proxies: []", "Proxy Group:", "proxy-groups:", "Rule:", "rules:").Replace(testTemplate)
+	for _, text := range []string{testTemplate, modern} {
+		tpl, err := ParseTemplate(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tpl.Config().String(); got != text {
+			t.Errorf("unchanged config does not round-trip:\n%s\nwant:\n%s", got, text)
+		}
+
+		c := tpl.Config()
+		c.Port = 7900
+		c.Rule = append([]string{"DOMAIN,example.com,Proxy"}, c.Rule...)
+		c.Hosts = map[string]string{"nas.local": "192.168.1.2"}
+		got := c.String()
+		for _, want := range []string{"port: 7900\n", "  enable: true # 开启 DNS\n", "  nas.local: 192.168.1.2\n",
+			"  - DOMAIN,example.com,Proxy\n  # 局域网\n  - DOMAIN-SUFFIX,local,DIRECT\n"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("merged config does not contain %q:\n%s", want, got)
+			}
+		}
+		if strings.Contains(got, "router.local") {
+			t.Errorf("hosts from the template are kept:\n%s", got)
+		}
+		if strings.Contains(text, "rules:") != strings.Contains(got, "rules:") {
+			t.Errorf("key names of the template are not kept:\n%s", got)
+		}
+		//修改配置不影响模板
+		if again := tpl.Config().String(); again != text {
+			t.Errorf("template is modified:\n%s", again)
+		}
+	}
+
+	mixed := testTemplate + "rules:\n  - MATCH,DIRECT\n"
+	if _, err := ParseTemplate(strings.NewReader(mixed)); err == nil {
+		t.Errorf("template using both Rule and rules should be rejected")
+	}
+}
+
+func TestMergeNode(t *testing.T) {
+	tests := []struct {
+		name  string
+		dst   string
+		src   string
+		exact bool
+		want  string
+	}{
+		{name: "keep comments", dst: "a: 1 # one\nb: 2\n", src: "a: 1\nb: 3\n", want: "a: 1 # one\nb: 3\n"},
+		{name: "keep unknown keys", dst: "a: 1\nx: keep\n", src: "a: 2\n", want: "a: 2\nx: keep\n"},
+		{name: "exact drops unknown keys", dst: "a: 1\nx: drop\n", src: "a: 2\n", exact: true, want: "a: 2\n"},
+		{name: "dynamic keys", dst: "hosts:\n  a: 1\n  b: 2\n", src: "hosts:\n  b: 3\n", want: "hosts:\n    b: 3\n"},
+		{name: "append new keys", dst: "a: 1\n", src: "a: 1\nb: 2\nc: \"\"\n", want: "a: 1\nb: 2\n"},
+		{name: "reuse sequence items", dst: "- a # first\n- b\n", src: "- c\n- a\n", want: "- c\n- a # first\n"},
+		{name: "scalar comment", dst: "a: 1 # note\n", src: "a: 2\n", want: "a: 2 # note\n"},
+	}
+	for _, tt := range tests {
+		dst, src := &yaml.Node{}, &yaml.Node{}
+		if err := yaml.Unmarshal([]byte(tt.dst), dst); err != nil {
+			t.Fatal(err)
+		}
+		if err := yaml.Unmarshal([]byte(tt.src), src); err != nil {
+			t.Fatal(err)
+		}
+		before, _ := yaml.Marshal(dst)
+		out := mergeNode(dst.Content[0], src.Content[0], tt.exact)
+		b, err := yaml.Marshal(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, b, tt.want)
+		}
+		if after, _ := yaml.Marshal(dst); string(after) != string(before) {
+			t.Errorf("%s: dst is modified", tt.name)
+		}
+	}
+}
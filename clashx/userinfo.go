@@ -0,0 +1,138 @@
+package clashx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//UserInfo 订阅的流量和到期信息，来自订阅响应的 Subscription-Userinfo 头.
+type UserInfo struct {
+	Upload   int64 `json:"upload"`
+	Download int64 `json:"download"`
+	Total    int64 `json:"total"`
+	//到期时间（Unix 时间戳），0 表示不过期
+	Expire int64 `json:"expire"`
+}
+
+//ParseUserInfo 解析 Subscription-Userinfo 头，格式为 upload=..; download=..; total=..; expire=..
+// 头为空或不包含任何字段时返回 nil.
+func ParseUserInfo(header string) *UserInfo {
+	info := &UserInfo{}
+	found := false
+	for _, item := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil {
+			f, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil {
+				continue
+			}
+			value = int64(f)
+		}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "upload":
+			info.Upload = value
+		case "download":
+			info.Download = value
+		case "total":
+			info.Total = value
+		case "expire":
+			info.Expire = value
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return info
+}
+
+//Merge 合并多个订阅的信息：流量累加，到期时间取最早的一个.
+func (u *UserInfo) Merge(other *UserInfo) *UserInfo {
+	if u == nil {
+		return other
+	}
+	if other == nil {
+		return u
+	}
+	out := &UserInfo{
+		Upload:   u.Upload + other.Upload,
+		Download: u.Download + other.Download,
+		Total:    u.Total + other.Total,
+		Expire:   u.Expire,
+	}
+	if out.Expire == 0 || (other.Expire != 0 && other.Expire < out.Expire) {
+		out.Expire = other.Expire
+	}
+	return out
+}
+
+//String 输出 Subscription-Userinfo 头的格式.
+func (u *UserInfo) String() string {
+	s := fmt.Sprintf("upload=%d; download=%d; total=%d", u.Upload, u.Download, u.Total)
+	if u.Expire > 0 {
+		s += fmt.Sprintf("; expire=%d", u.Expire)
+	}
+	return s
+}
+
+//Remaining 剩余流量（字节）.
+func (u *UserInfo) Remaining() int64 {
+	if remaining := u.Total - u.Upload - u.Download; remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+//AddUserInfoProxies 在主分组（第一个 select 分组）末尾加入展示剩余流量和到期时间的节点，这些节点不可用于连接.
+func (m *Config) AddUserInfoProxies(info *UserInfo) {
+	if info == nil {
+		return
+	}
+	var names []string
+	if info.Total > 0 {
+		names = append(names, "剩余流量："+formatBytes(info.Remaining()))
+	}
+	if info.Expire > 0 {
+		names = append(names, "过期时间："+time.Unix(info.Expire, 0).Format("2006-01-02"))
+	}
+	var main *ProxyGroup
+	for _, g := range m.ProxyGroup {
+		if g.Type == GroupSelect {
+			main = g
+			break
+		}
+	}
+	for i, name := range names {
+		if m.hasPolicy(name) {
+			continue
+		}
+		//信息节点指向本机的不同端口，避免被当作重复节点
+		m.Proxy = append(m.Proxy, &Proxy{Name: name, Type: "socks5", Server: "127.0.0.1", Port: i + 1})
+		if main != nil {
+			main.addProxy(name)
+		}
+	}
+}
+
+//formatBytes 以 1024 为进制输出易读的流量.
+func formatBytes(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	value := float64(n)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %s", n, units[0])
+	}
+	return fmt.Sprintf("%.2f %s", value, units[i])
+}
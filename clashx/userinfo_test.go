@@ -0,0 +1,120 @@
+package clashx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseUserInfo(t *testing.T) {
+	tests := []struct {
+		header string
+		want   *UserInfo
+	}{
+		{header: "upload=1024; download=2048; total=10240; expire=1700000000", want: &UserInfo{Upload: 1024, Download: 2048, Total: 10240, Expire: 1700000000}},
+		{header: " Upload = 1 ;DOWNLOAD=2;total=3", want: &UserInfo{Upload: 1, Download: 2, Total: 3}},
+		{header: "upload=1.5e3; download=2; total=1.073741824e10; expire=", want: &UserInfo{Upload: 1500, Download: 2, Total: 10737418240}},
+		{header: "upload=1; unknown=2; total=abc", want: &UserInfo{Upload: 1}},
+		{header: "unknown=2; total"},
+		{header: ""},
+	}
+	for _, tt := range tests {
+		if got := ParseUserInfo(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseUserInfo(%q) = %+v, want %+v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestUserInfoMerge(t *testing.T) {
+	a := &UserInfo{Upload: 1, Download: 2, Total: 10, Expire: 2000}
+	tests := []struct {
+		name  string
+		u     *UserInfo
+		other *UserInfo
+		want  *UserInfo
+	}{
+		{name: "nil", want: nil},
+		{name: "nil receiver", other: a, want: a},
+		{name: "nil other", u: a, want: a},
+		{name: "earliest expire", u: a, other: &UserInfo{Upload: 3, Download: 4, Total: 20, Expire: 1000}, want: &UserInfo{Upload: 4, Download: 6, Total: 30, Expire: 1000}},
+		{name: "later expire", u: a, other: &UserInfo{Total: 5, Expire: 3000}, want: &UserInfo{Upload: 1, Download: 2, Total: 15, Expire: 2000}},
+		{name: "other never expires", u: a, other: &UserInfo{Total: 5}, want: &UserInfo{Upload: 1, Download: 2, Total: 15, Expire: 2000}},
+		{name: "never expires", u: &UserInfo{Total: 5}, other: a, want: &UserInfo{Upload: 1, Download: 2, Total: 15, Expire: 2000}},
+	}
+	for _, tt := range tests {
+		if got := tt.u.Merge(tt.other); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Merge() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if *a != (UserInfo{Upload: 1, Download: 2, Total: 10, Expire: 2000}) {
+		t.Errorf("Merge() modifies the receiver")
+	}
+}
+
+func TestUserInfoString(t *testing.T) {
+	tests := []struct {
+		info *UserInfo
+		want string
+	}{
+		{info: &UserInfo{Upload: 1, Download: 2, Total: 3, Expire: 4}, want: "upload=1; download=2; total=3; expire=4"},
+		{info: &UserInfo{Total: 3}, want: "upload=0; download=0; total=3"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+		if got := ParseUserInfo(tt.info.String()); !reflect.DeepEqual(got, tt.info) {
+			t.Errorf("ParseUserInfo(%q) = %+v, want %+v", tt.want, got, tt.info)
+		}
+	}
+}
+
+func TestAddUserInfoProxies(t *testing.T) {
+	c := &Config{
+		Proxy:      []*Proxy{testProxy("香港 01", 8388)},
+		ProxyGroup: []*ProxyGroup{{Name: "Auto", Type: GroupURLTest, Proxies: []string{"香港 01"}}, {Name: "Proxy", Type: GroupSelect, Proxies: []string{"Auto"}}},
+	}
+	expire := time.Date(2030, 1, 2, 12, 0, 0, 0, time.Local).Unix()
+	info := &UserInfo{Upload: 1 << 30, Download: 1 << 29, Total: 10 << 30, Expire: expire}
+	c.AddUserInfoProxies(info)
+	c.AddUserInfoProxies(info)
+	c.AddUserInfoProxies(nil)
+
+	want := []string{"Auto", "剩余流量：8.50 GB", "过期时间：2030-01-02"}
+	if !reflect.DeepEqual(c.ProxyGroup[1].Proxies, want) {
+		t.Errorf("main group = %q, want %q", c.ProxyGroup[1].Proxies, want)
+	}
+	if len(c.ProxyGroup[0].Proxies) != 1 {
+		t.Errorf("url-test group = %q, want only 香港 01", c.ProxyGroup[0].Proxies)
+	}
+	if len(c.Proxy) != 3 {
+		t.Fatalf("proxies = %d, want 3", len(c.Proxy))
+	}
+	if _, warnings := dedupProxies(c.Proxy, nil); len(warnings) > 0 {
+		t.Errorf("user info proxies are duplicates: %q", warnings)
+	}
+	if err := c.checkGroups(); err != nil {
+		t.Errorf("checkGroups() error = %v", err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{n: 0, want: "0 B"},
+		{n: 1023, want: "1023 B"},
+		{n: 1024, want: "1.00 KB"},
+		{n: 1536 << 20, want: "1.50 GB"},
+		{n: 1 << 60, want: "1024.00 PB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+	if got := (&UserInfo{Upload: 5, Download: 6, Total: 10}).Remaining(); got != 0 {
+		t.Errorf("Remaining() of an exhausted subscription = %d, want 0", got)
+	}
+}
@@ -13,5 +13,8 @@ type V2rayConfig struct {
 	TLS  string      `json:"tls"`
 	V    json.Number `json:"v"`
 	Aid  json.Number `json:"aid"`
-	Type string      `json:"type"`
+	//伪装类型（none / http 等），不是加密方式
+	Type string `json:"type"`
+	//加密方式，大多数分享链接省略该字段
+	Scy string `json:"scy"`
 }
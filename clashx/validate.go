@@ -0,0 +1,142 @@
+package clashx
+
+import (
+	"fmt"
+	"strings"
+)
+
+//proxyRequired 各类型节点必须设置的字段.
+var proxyRequired = map[string][]string{
+	"vmess":  {"uuid", "cipher"},
+	"ss":     {"cipher", "password"},
+	"ssr":    {"cipher", "password"},
+	"trojan": {"password"},
+	"socks5": nil,
+	"http":   nil,
+	"snell":  nil,
+}
+
+//ValidationError 配置校验失败时返回的错误，包含发现的全部问题.
+type ValidationError struct {
+	Problems []string `json:"problems"`
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration -> " + strings.Join(e.Problems, "; ")
+}
+
+//Validate 检查配置能否被 clash 正常加载：节点名称唯一、节点字段完整、端口合法、分组成员均存在、
+// 规则的策略均存在，并且有且只有一条位于末尾的 MATCH 规则. 存在问题时返回 *ValidationError.
+func (m *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !validPort(m.Port) {
+		add("invalid port -> %d", m.Port)
+	}
+	if !validPort(m.SocksPort) {
+		add("invalid socks-port -> %d", m.SocksPort)
+	}
+
+	names := make(map[string]bool, len(m.Proxy))
+	for _, p := range m.Proxy {
+		if p.Name == "" {
+			add("proxy name is empty -> %s:%d", p.Server, p.Port)
+			continue
+		}
+		if names[p.Name] {
+			add("duplicate proxy name -> %s", p.Name)
+		}
+		names[p.Name] = true
+		for _, problem := range p.validate() {
+			add("proxy %s %s", p.Name, problem)
+		}
+	}
+
+	if err := m.checkGroups(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	final := -1
+	for i, line := range m.Rule {
+		r, err := ParseRule(line)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if !m.hasPolicy(r.Target) {
+			add("rule references unknown proxy or group -> %s", line)
+		}
+		if _, ok := m.RuleProviders[r.Payload]; r.Type == RuleRuleSet && !ok {
+			add("rule references unknown rule provider -> %s", line)
+		}
+		if r.IsFinal() {
+			if final >= 0 {
+				add("duplicate %s rule -> %s", r.Type, line)
+			}
+			final = i
+		}
+	}
+	switch {
+	case final < 0:
+		add("missing MATCH rule")
+	case final != len(m.Rule)-1:
+		add("MATCH rule must be the last rule -> %s", m.Rule[final])
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+//validate 检查节点的必填字段，返回发现的问题.
+func (m *Proxy) validate() []string {
+	var problems []string
+	required, ok := proxyRequired[m.Type]
+	if !ok {
+		return append(problems, "unsupported type -> "+m.Type)
+	}
+	if m.Server == "" {
+		problems = append(problems, "server is empty")
+	}
+	if m.Port <= 0 || !validPort(m.Port) {
+		problems = append(problems, fmt.Sprintf("invalid port -> %d", m.Port))
+	}
+	for _, field := range required {
+		var value string
+		switch field {
+		case "uuid":
+			value = m.UUID
+		case "cipher":
+			value = m.Cipher
+		case "password":
+			value = m.Password
+		}
+		if value == "" {
+			problems = append(problems, field+" is empty")
+		}
+	}
+	return problems
+}
+
+//dropInvalidProxies 移除字段不完整或类型不支持的节点，单个节点无效时不影响整个配置，返回保留的节点以及说明被移除节点的警告.
+func dropInvalidProxies(proxies []*Proxy) ([]*Proxy, []string) {
+	var warnings []string
+	valid := make([]*Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		if problems := p.validate(); len(problems) > 0 {
+			warnings = append(warnings, fmt.Sprintf("invalid node removed -> %s (%s)", p.Name, strings.Join(problems, ", ")))
+			continue
+		}
+		valid = append(valid, p)
+	}
+	return valid, warnings
+}
+
+//validPort 端口是否合法，0 表示不启用.
+func validPort(port int) bool {
+	return port >= 0 && port <= 65535
+}
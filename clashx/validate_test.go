@@ -0,0 +1,140 @@
+package clashx
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func testProxy(name string, port int) *Proxy {
+	return &Proxy{Name: name, Type: "ss", Server: "1.2.3.4", Port: port, Cipher: "aes-128-gcm", Password: "secret"}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Port:       7890,
+			Proxy:      []*Proxy{testProxy("香港 01", 8388), testProxy("日本 01", 8389)},
+			ProxyGroup: []*ProxyGroup{{Name: "Proxy", Type: GroupSelect, Proxies: []string{"Auto", "香港 01", "DIRECT"}}, {Name: "Auto", Type: GroupURLTest, Proxies: []string{"香港 01", "日本 01"}, Url: "http://www.gstatic.com/generate_204", Interval: 300}},
+			Rule:       []string{"DOMAIN-SUFFIX,local,DIRECT", "MATCH,Proxy"},
+		}
+	}
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		problems []string
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{name: "invalid port", modify: func(c *Config) { c.SocksPort = 70000 }, problems: []string{"invalid socks-port -> 70000"}},
+		{
+			name:     "invalid node",
+			modify:   func(c *Config) { c.Proxy[1].Cipher = ""; c.Proxy[1].Port = 0 },
+			problems: []string{"proxy 日本 01 invalid port -> 0", "proxy 日本 01 cipher is empty"},
+		},
+		{
+			name:     "unsupported node type",
+			modify:   func(c *Config) { c.Proxy[1].Type = "wireguard" },
+			problems: []string{"proxy 日本 01 unsupported type -> wireguard"},
+		},
+		{
+			name:     "duplicate node",
+			modify:   func(c *Config) { c.Proxy[1].Name = "香港 01" },
+			problems: []string{"duplicate proxy name -> 香港 01", "proxy group Auto references unknown proxy or group -> 日本 01"},
+		},
+		{
+			name:     "dangling group member",
+			modify:   func(c *Config) { c.ProxyGroup[0].Proxies = append(c.ProxyGroup[0].Proxies, "美国 01") },
+			problems: []string{"proxy group Proxy references unknown proxy or group -> 美国 01"},
+		},
+		{
+			name:     "group cycle",
+			modify:   func(c *Config) { c.ProxyGroup[1].Proxies = append(c.ProxyGroup[1].Proxies, "Proxy") },
+			problems: []string{"proxy group reference cycle -> Proxy -> Auto -> Proxy"},
+		},
+		{
+			name:     "dialer-proxy cycle",
+			modify:   func(c *Config) { c.Proxy[0].DialerProxy = "Auto" },
+			problems: []string{"proxy group reference cycle -> Proxy -> Auto -> 香港 01 -> Auto"},
+		},
+		{
+			name:     "unknown policy and rule provider",
+			modify:   func(c *Config) { c.Rule = append([]string{"DOMAIN,a.com,Nope", "RULE-SET,ads,REJECT"}, c.Rule...) },
+			problems: []string{"rule references unknown proxy or group -> DOMAIN,a.com,Nope", "rule references unknown rule provider -> RULE-SET,ads,REJECT"},
+		},
+		{
+			name:     "missing MATCH",
+			modify:   func(c *Config) { c.Rule = c.Rule[:1] },
+			problems: []string{"missing MATCH rule"},
+		},
+		{
+			name:     "MATCH is not the last rule",
+			modify:   func(c *Config) { c.Rule = []string{"MATCH,Proxy", "DOMAIN-SUFFIX,local,DIRECT"} },
+			problems: []string{"MATCH rule must be the last rule -> MATCH,Proxy"},
+		},
+		{
+			name:     "duplicate MATCH",
+			modify:   func(c *Config) { c.Rule = append(c.Rule, "MATCH,DIRECT") },
+			problems: []string{"duplicate MATCH rule -> MATCH,DIRECT"},
+		},
+	}
+	for _, tt := range tests {
+		c := valid()
+		tt.modify(c)
+		err := c.Validate()
+		if tt.problems == nil {
+			if err != nil {
+				t.Errorf("%s: Validate() = %v", tt.name, err)
+			}
+			continue
+		}
+		e, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("%s: Validate() = %v, want *ValidationError", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(e.Problems, tt.problems) {
+			t.Errorf("%s: problems = %q, want %q", tt.name, e.Problems, tt.problems)
+		}
+	}
+}
+
+func TestBuildDropsInvalidProxies(t *testing.T) {
+	broken := testProxy("坏节点", 8390)
+	broken.Cipher = ""
+	proxies := []*Proxy{testProxy("香港 01", 8388), broken, testProxy("日本 01", 8389)}
+	c, err := Build(GetTemplate(TemplateMinimal), proxies, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range c.Proxy {
+		names = append(names, p.Name)
+	}
+	if want := []string{"香港 01", "日本 01"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("proxies = %q, want %q", names, want)
+	}
+	if want := []string{"invalid node removed -> 坏节点 (cipher is empty)"}; !reflect.DeepEqual(c.Warnings, want) {
+		t.Errorf("warnings = %q, want %q", c.Warnings, want)
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestBuildKeepsVmessWithoutType(t *testing.T) {
+	link := "vmess://" + base64.StdEncoding.EncodeToString([]byte(`{"v":"2","ps":"日本 01","add":"jp.example.com","port":"443","id":"b831381d-6324-4d53-ad4f-8cda48b30811","aid":"0","net":"ws","type":"","path":"/ray","tls":"tls"}`))
+	proxies, err := GetConverter("vmess").Convert(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Build(GetTemplate(TemplateMinimal), proxies, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Proxy) != 1 || len(c.Warnings) != 0 {
+		t.Errorf("proxies = %d, warnings = %q", len(c.Proxy), c.Warnings)
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
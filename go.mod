@@ -3,7 +3,8 @@ module github.com/lifei6671/clashx-convert
 go 1.14
 
 require (
+	github.com/oschwald/maxminddb-golang v1.6.0
 	github.com/urfave/cli/v2 v2.2.0
 	gopkg.in/yaml.v2 v2.2.8
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//fileWatchInterval 检查本地文件订阅是否变化的间隔.
+const fileWatchInterval = 10 * time.Second
+
+//Fetcher 获取订阅、规则集等地址的内容，按地址的 scheme 注册.
+type Fetcher interface {
+	Fetch(urlStr string) ([]byte, http.Header, error)
+}
+
+//changeDetector 可以检测内容是否变化的 Fetcher，使用该地址的托管配置会定期检查，内容变化时立即更新.
+type changeDetector interface {
+	Changed(urlStr string) bool
+}
+
+var (
+	localFiles = &fileFetcher{states: make(map[string]fileState)}
+	fetchers   = map[string]Fetcher{
+		"http":   &httpFetcher{},
+		"https":  &httpFetcher{},
+		"file":   localFiles,
+		"data":   &dataFetcher{},
+		"inline": &inlineFetcher{},
+	}
+	fetcherLock = &sync.RWMutex{}
+)
+
+//RegisterFetcher 注册一个 scheme 的 Fetcher，已存在时替换.
+func RegisterFetcher(scheme string, f Fetcher) {
+	fetcherLock.Lock()
+	defer fetcherLock.Unlock()
+	fetchers[strings.ToLower(scheme)] = f
+}
+
+//getFetcher 获取地址对应的 Fetcher.
+func getFetcher(urlStr string) (Fetcher, error) {
+	i := strings.Index(urlStr, ":")
+	if i <= 0 {
+		return nil, fmt.Errorf("missing url scheme -> %s", urlStr)
+	}
+	fetcherLock.RLock()
+	defer fetcherLock.RUnlock()
+	if f, ok := fetchers[strings.ToLower(urlStr[:i])]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported url scheme -> %s", urlStr[:i])
+}
+
+//SetFileRoot 设置 file:// 地址允许读取的目录，为空时不允许读取本地文件.
+func SetFileRoot(root string) error {
+	if root == "" {
+		localFiles.root = ""
+		return nil
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	localFiles.root = abs
+	return nil
+}
+
+//httpFetcher 通过 HTTP(S) 获取内容，不校验证书.
+type httpFetcher struct {
+}
+
+func (m *httpFetcher) Fetch(urlStr string) ([]byte, http.Header, error) {
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	client := &http.Client{Transport: tr}
+	resp, err := client.Get(urlStr)
+
+	if err != nil {
+		log.Println("Failed to get remote response ->", err)
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Failed to read remote response -> %s %s", urlStr, err)
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to read remote response -> %s http_code=%d body=%s", urlStr, resp.StatusCode, string(body))
+		return nil, nil, fmt.Errorf("http_code=%d", resp.StatusCode)
+	}
+	return body, resp.Header, nil
+}
+
+//fileFetcher 读取 file:// 地址指向的本地文件，只允许读取 root 目录中的文件，相对路径也以 root 为基准.
+// 每次读取时记录文件的修改时间、大小和内容摘要，用于检测文件是否变化.
+type fileFetcher struct {
+	root   string
+	lock   sync.Mutex
+	states map[string]fileState
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+//path 解析 file:// 地址，支持 file:///绝对路径 以及 file:相对路径.
+func (m *fileFetcher) path(urlStr string) (string, error) {
+	if m.root == "" {
+		return "", fmt.Errorf("local file source is disabled -> %s", urlStr)
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return "", err
+	}
+	p := u.Path
+	if u.Opaque != "" {
+		p = u.Opaque
+	} else if u.Host != "" && u.Host != "localhost" {
+		p = u.Host + p
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(m.root, p)
+	}
+	p = filepath.Clean(p)
+	if rel, err := filepath.Rel(m.root, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("local file is outside of file root -> %s", urlStr)
+	}
+	return p, nil
+}
+
+func (m *fileFetcher) Fetch(urlStr string) ([]byte, http.Header, error) {
+	p, err := m.path(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.lock.Lock()
+	m.states[p] = fileState{modTime: info.ModTime(), size: info.Size(), hash: sha256.Sum256(body)}
+	m.lock.Unlock()
+	return body, nil, nil
+}
+
+//Changed 文件自上次读取后是否发生变化，修改时间和大小均未变化时认为没有变化，否则比较内容摘要.
+func (m *fileFetcher) Changed(urlStr string) bool {
+	p, err := m.path(urlStr)
+	if err != nil {
+		return false
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	state, ok := m.states[p]
+	if !ok {
+		return false
+	}
+	info, err := os.Stat(p)
+	if err != nil || (info.ModTime().Equal(state.modTime) && info.Size() == state.size) {
+		return false
+	}
+	body, err := ioutil.ReadFile(p)
+	if err != nil {
+		return false
+	}
+	if hash := sha256.Sum256(body); hash != state.hash {
+		return true
+	}
+	state.modTime, state.size = info.ModTime(), info.Size()
+	m.states[p] = state
+	return false
+}
+
+//dataFetcher 解析 data: 地址，如 data:text/plain;base64,dm1lc3M6Ly8u.
+type dataFetcher struct {
+}
+
+func (m *dataFetcher) Fetch(urlStr string) ([]byte, http.Header, error) {
+	i := strings.Index(urlStr, ",")
+	if i < 0 {
+		return nil, nil, fmt.Errorf("invalid data url -> %s", urlStr)
+	}
+	meta, data := urlStr[len("data:"):i], urlStr[i+1:]
+	if strings.HasSuffix(meta, ";base64") {
+		data = strings.TrimRight(data, "=")
+		body, err := base64.RawStdEncoding.DecodeString(data)
+		if err != nil {
+			if body, err = base64.RawURLEncoding.DecodeString(data); err != nil {
+				return nil, nil, fmt.Errorf("invalid data url -> %s", err)
+			}
+		}
+		return body, nil, nil
+	}
+	body, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid data url -> %s", err)
+	}
+	return []byte(body), nil, nil
+}
+
+//inlineFetcher 直接使用 inline: 之后的内容作为订阅内容.
+type inlineFetcher struct {
+}
+
+func (m *inlineFetcher) Fetch(urlStr string) ([]byte, http.Header, error) {
+	return bytes.TrimSpace([]byte(urlStr[len("inline:"):])), nil, nil
+}
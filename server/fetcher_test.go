@@ -0,0 +1,184 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestFileFetcher(t *testing.T) (*fileFetcher, string) {
+	root, err := ioutil.TempDir("", "clashx-fetcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(root) })
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &fileFetcher{root: root, states: make(map[string]fileState)}, root
+}
+
+func TestFileFetcherPath(t *testing.T) {
+	f, root := newTestFileFetcher(t)
+	tests := []struct {
+		url  string
+		want string
+		err  bool
+	}{
+		{url: "file:a.txt", want: filepath.Join(root, "a.txt")},
+		{url: "file:sub/a.txt", want: filepath.Join(root, "sub", "a.txt")},
+		{url: "file://" + root + "/sub/a.txt", want: filepath.Join(root, "sub", "a.txt")},
+		{url: "file://localhost" + root + "/a.txt", want: filepath.Join(root, "a.txt")},
+		{url: "file:sub/../a.txt", want: filepath.Join(root, "a.txt")},
+		{url: "file:../a.txt", err: true},
+		{url: "file:sub/../../a.txt", err: true},
+		{url: "file:///etc/passwd", err: true},
+		{url: "file://" + root + "/../a.txt", err: true},
+		{url: "file://" + root + "-other/a.txt", err: true},
+	}
+	for _, tt := range tests {
+		got, err := f.path(tt.url)
+		if (err != nil) != tt.err {
+			t.Errorf("path(%q) error = %v, want error %v", tt.url, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("path(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	disabled := &fileFetcher{states: make(map[string]fileState)}
+	if _, err := disabled.path("file:a.txt"); err == nil {
+		t.Errorf("path() without file root should fail")
+	}
+}
+
+func TestFileFetcherChanged(t *testing.T) {
+	f, root := newTestFileFetcher(t)
+	p := filepath.Join(root, "sub.txt")
+	write := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write("vmess://a", start)
+
+	if f.Changed("file:sub.txt") {
+		t.Errorf("Changed() before the first fetch = true")
+	}
+	body, header, err := f.Fetch("file:sub.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "vmess://a" || header != nil {
+		t.Errorf("Fetch() = %q %v", body, header)
+	}
+
+	steps := []struct {
+		name    string
+		content string
+		modTime time.Time
+		want    bool
+	}{
+		{name: "untouched", content: "vmess://a", modTime: start, want: false},
+		{name: "touched with same content", content: "vmess://a", modTime: start.Add(time.Minute), want: false},
+		{name: "same size, new content", content: "vmess://b", modTime: start.Add(2 * time.Minute), want: true},
+	}
+	for _, step := range steps {
+		write(step.content, step.modTime)
+		if got := f.Changed("file:sub.txt"); got != step.want {
+			t.Errorf("%s: Changed() = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	if _, _, err := f.Fetch("file:missing.txt"); err == nil {
+		t.Errorf("Fetch() of a missing file should fail")
+	}
+	if _, _, err := f.Fetch("file:../sub.txt"); err == nil {
+		t.Errorf("Fetch() outside of file root should fail")
+	}
+}
+
+func TestDataFetcher(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+		err  bool
+	}{
+		{url: "data:,vmess%3A%2F%2Fa%0Avmess%3A%2F%2Fb", want: "vmess://a\nvmess://b"},
+		{url: "data:text/plain,hello", want: "hello"},
+		{url: "data:text/plain;base64,aGVsbG8gd29ybGQ=", want: "hello world"},
+		{url: "data:;base64,aGVsbG8gd29ybGQ", want: "hello world"},
+		{url: "data:;base64,Pz8_Pw", want: "????"},
+		{url: "data:;base64,Pz8/Pw==", want: "????"},
+		{url: "data:text/plain", err: true},
+		{url: "data:;base64,!!!", err: true},
+		{url: "data:,%zz", err: true},
+	}
+	f := &dataFetcher{}
+	for _, tt := range tests {
+		body, _, err := f.Fetch(tt.url)
+		if (err != nil) != tt.err {
+			t.Errorf("Fetch(%q) error = %v, want error %v", tt.url, err, tt.err)
+			continue
+		}
+		if string(body) != tt.want {
+			t.Errorf("Fetch(%q) = %q, want %q", tt.url, body, tt.want)
+		}
+	}
+}
+
+func TestInlineFetcher(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "inline:vmess://a", want: "vmess://a"},
+		{url: "inline:\n  vmess://a\nvmess://b\n", want: "vmess://a\nvmess://b"},
+		{url: "inline:", want: ""},
+	}
+	f := &inlineFetcher{}
+	for _, tt := range tests {
+		body, _, err := f.Fetch(tt.url)
+		if err != nil {
+			t.Errorf("Fetch(%q) error = %v", tt.url, err)
+			continue
+		}
+		if string(body) != tt.want {
+			t.Errorf("Fetch(%q) = %q, want %q", tt.url, body, tt.want)
+		}
+	}
+}
+
+func TestGetFetcher(t *testing.T) {
+	tests := []struct {
+		url  string
+		want Fetcher
+		err  bool
+	}{
+		{url: "https://example.com/sub", want: fetchers["https"]},
+		{url: "HTTP://example.com/sub", want: fetchers["http"]},
+		{url: "file:sub.txt", want: localFiles},
+		{url: "data:,a", want: fetchers["data"]},
+		{url: "inline:a", want: fetchers["inline"]},
+		{url: "example.com/sub", err: true},
+		{url: ":a", err: true},
+		{url: "ftp://example.com/sub", err: true},
+	}
+	for _, tt := range tests {
+		got, err := getFetcher(tt.url)
+		if (err != nil) != tt.err {
+			t.Errorf("getFetcher(%q) error = %v, want error %v", tt.url, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("getFetcher(%q) = %T, want %T", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lifei6671/clashx-convert/clashx"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+//geoIP GEOIP 规则匹配测试使用的 IP 数据库.
+var geoIP *clashx.GeoIP
+
+//SetGeoIP 设置 GEOIP 规则匹配测试使用的 mmdb 数据库.
+func SetGeoIP(path string) error {
+	g, err := clashx.OpenGeoIP(path)
+	if err != nil {
+		return err
+	}
+	geoIP = g
+	return nil
+}
+
+//match 测试域名或 IP 在托管配置中会命中的规则和策略.
+func match(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	content, ok := cache.Load(name)
+	if !ok {
+		w.WriteHeader(404)
+		_, _ = fmt.Fprint(w, "Config does not exist ->"+name)
+		return
+	}
+	c := content.(*httpCache)
+	if c.snapshot() == nil {
+		if err := c.refresh(); err != nil {
+			w.WriteHeader(500)
+			_, _ = fmt.Fprint(w, err)
+			return
+		}
+	}
+	target := &clashx.MatchTarget{
+		Domain:  r.FormValue("domain"),
+		RuleSet: loadRuleSet,
+	}
+	if s := r.FormValue("ip"); s != "" {
+		if target.IP = net.ParseIP(s); target.IP == nil {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, "invalid ip -> "+s)
+			return
+		}
+	}
+	if s := r.FormValue("port"); s != "" {
+		port, err := strconv.Atoi(s)
+		if err != nil || port <= 0 || port > 65535 {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, "invalid port -> "+s)
+			return
+		}
+		target.Port = port
+	}
+	if r.FormValue("resolve") != "false" {
+		target.Resolve = resolve
+	}
+	if geoIP != nil {
+		target.GeoIP = geoIP.Country
+	}
+	result, err := c.snapshot().Match(target)
+	if err != nil {
+		w.WriteHeader(400)
+		_, _ = fmt.Fprint(w, err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func resolve(domain string) (net.IP, error) {
+	ips, err := net.LookupIP(domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip, nil
+		}
+	}
+	return ips[0], nil
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+//mirrorSets 各订阅的镜像地址及其健康状况，键为按顺序拼接的全部地址.
+var mirrorSets = &sync.Map{}
+
+//mirrorHealth 一个订阅地址最近的拉取情况.
+type mirrorHealth struct {
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+	//是否为最近一次拉取成功的地址，下次拉取时优先使用
+	Preferred   bool      `json:"preferred"`
+	LastSuccess time.Time `json:"last_success"`
+	LastFailure time.Time `json:"last_failure"`
+	LastError   string    `json:"last_error,omitempty"`
+	//连续失败的次数
+	Failures int `json:"failures"`
+}
+
+//mirrorSet 同一订阅的多个镜像地址.
+type mirrorSet struct {
+	lock sync.Mutex
+	urls []string
+	//最近一次拉取成功的地址
+	last   string
+	health map[string]*mirrorHealth
+}
+
+//getMirrorSet 获取一组镜像地址的状态，不存在时创建.
+func getMirrorSet(urls []string) *mirrorSet {
+	key := strings.Join(urls, "\n")
+	if v, ok := mirrorSets.Load(key); ok {
+		return v.(*mirrorSet)
+	}
+	set := &mirrorSet{urls: urls, health: make(map[string]*mirrorHealth, len(urls))}
+	for _, u := range urls {
+		set.health[u] = &mirrorHealth{URL: u, Healthy: true}
+	}
+	v, _ := mirrorSets.LoadOrStore(key, set)
+	return v.(*mirrorSet)
+}
+
+//order 拉取时尝试的顺序：最近一次成功的地址优先，其余按配置的顺序.
+func (m *mirrorSet) order() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	urls := make([]string, 0, len(m.urls))
+	if m.last != "" {
+		urls = append(urls, m.last)
+	}
+	for _, u := range m.urls {
+		if u != m.last {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+//report 记录一次拉取的结果.
+func (m *mirrorSet) report(urlStr string, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	h := m.health[urlStr]
+	if err != nil {
+		h.Healthy = false
+		h.LastFailure = time.Now()
+		h.LastError = err.Error()
+		h.Failures++
+		return
+	}
+	h.Healthy = true
+	h.LastSuccess = time.Now()
+	h.LastError = ""
+	h.Failures = 0
+	m.last = urlStr
+}
+
+//preferred 最近一次拉取成功的地址，尚未成功过时为空.
+func (m *mirrorSet) preferred() string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.last
+}
+
+//prefer 尚未拉取成功过时，将指定地址设为优先尝试的地址，地址不属于这组镜像时忽略.
+func (m *mirrorSet) prefer(urlStr string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.health[urlStr]; ok && m.last == "" {
+		m.last = urlStr
+	}
+}
+
+//status 按配置的顺序返回各地址的健康状况.
+func (m *mirrorSet) status() []*mirrorHealth {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	health := make([]*mirrorHealth, 0, len(m.urls))
+	for _, u := range m.urls {
+		h := *m.health[u]
+		h.Preferred = u == m.last
+		health = append(health, &h)
+	}
+	return health
+}
+
+//saveMirrors 将各订阅最近一次拉取成功的地址记录到 LastMirrors 中，以便随备份保存，有变化时返回 true. 调用方需要持有写锁.
+func (c *httpCache) saveMirrors() bool {
+	changed := false
+	for _, urls := range c.mirrors() {
+		last := getMirrorSet(urls).preferred()
+		if last == "" || c.LastMirrors[urls[0]] == last {
+			continue
+		}
+		if c.LastMirrors == nil {
+			c.LastMirrors = make(map[string]string)
+		}
+		c.LastMirrors[urls[0]] = last
+		changed = true
+	}
+	return changed
+}
+
+//restoreMirrors 从备份恢复后，优先使用各订阅上一次拉取成功的地址.
+func (c *httpCache) restoreMirrors() {
+	for _, urls := range c.mirrors() {
+		if last, ok := c.LastMirrors[urls[0]]; ok {
+			getMirrorSet(urls).prefer(last)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/lifei6671/clashx-convert/clashx"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+//resetMirrorSets 清空镜像地址的状态，测试结束后恢复.
+func resetMirrorSets(t *testing.T) {
+	old := mirrorSets
+	mirrorSets = &sync.Map{}
+	t.Cleanup(func() { mirrorSets = old })
+}
+
+func TestMirrorSet(t *testing.T) {
+	resetMirrorSets(t)
+	urls := []string{"https://a.example.com/sub", "https://b.example.com/sub", "https://c.example.com/sub"}
+	set := getMirrorSet(urls)
+	if getMirrorSet(append([]string(nil), urls...)) != set {
+		t.Errorf("getMirrorSet() with the same urls returns a new set")
+	}
+	if getMirrorSet(urls[:2]) == set {
+		t.Errorf("getMirrorSet() with different urls returns the same set")
+	}
+
+	steps := []struct {
+		url      string
+		err      error
+		order    []string
+		failures []int
+	}{
+		{url: urls[1], err: fmt.Errorf("timeout"), order: urls, failures: []int{0, 1, 0}},
+		{url: urls[2], order: []string{urls[2], urls[0], urls[1]}, failures: []int{0, 1, 0}},
+		{url: urls[1], err: fmt.Errorf("timeout"), order: []string{urls[2], urls[0], urls[1]}, failures: []int{0, 2, 0}},
+		{url: urls[2], err: fmt.Errorf("status 500"), order: []string{urls[2], urls[0], urls[1]}, failures: []int{0, 2, 1}},
+		{url: urls[1], order: []string{urls[1], urls[0], urls[2]}, failures: []int{0, 0, 1}},
+	}
+	for i, step := range steps {
+		set.report(step.url, step.err)
+		if got := set.order(); !reflect.DeepEqual(got, step.order) {
+			t.Errorf("step %d: order() = %q, want %q", i, got, step.order)
+		}
+		for j, h := range set.status() {
+			if h.URL != urls[j] || h.Failures != step.failures[j] || h.Healthy != (step.failures[j] == 0) || h.Preferred != (h.URL == step.order[0] && set.preferred() != "") {
+				t.Errorf("step %d: status()[%d] = %+v, want %s with %d failures", i, j, h, urls[j], step.failures[j])
+			}
+		}
+	}
+	status := set.status()
+	if status[2].LastError != "status 500" || status[1].LastError != "" || status[1].LastSuccess.IsZero() || status[1].LastFailure.IsZero() {
+		t.Errorf("status() = %+v %+v", status[1], status[2])
+	}
+	status[0].Healthy = false
+	if !set.status()[0].Healthy {
+		t.Errorf("status() returns the internal state")
+	}
+
+	fresh := getMirrorSet(urls[:2])
+	fresh.prefer("https://unknown.example.com/sub")
+	if fresh.preferred() != "" {
+		t.Errorf("prefer() accepts an unknown url")
+	}
+	fresh.prefer(urls[1])
+	fresh.prefer(urls[0])
+	if got := fresh.order(); !reflect.DeepEqual(got, []string{urls[1], urls[0]}) {
+		t.Errorf("order() after prefer() = %q", got)
+	}
+	fresh.report(urls[0], nil)
+	if fresh.preferred() != urls[0] {
+		t.Errorf("preferred() = %q after a successful fetch of %q", fresh.preferred(), urls[0])
+	}
+}
+
+func TestSaveRestoreMirrors(t *testing.T) {
+	resetMirrorSets(t)
+	c := &httpCache{
+		Name: "mirror-test",
+		Sources: []*clashx.Source{
+			{Name: "A", URL: "https://a.example.com/sub", Mirrors: []string{"https://a2.example.com/sub"}},
+			{Name: "B", URL: "https://b.example.com/sub", Mirrors: []string{"https://b2.example.com/sub", "https://b3.example.com/sub"}},
+		},
+	}
+	if c.saveMirrors() || c.LastMirrors != nil {
+		t.Errorf("saveMirrors() before any fetch = %v", c.LastMirrors)
+	}
+	getMirrorSet(c.Sources[0].URLs()).report("https://a2.example.com/sub", nil)
+	getMirrorSet(c.Sources[1].URLs()).report("https://b.example.com/sub", fmt.Errorf("timeout"))
+	getMirrorSet(c.Sources[1].URLs()).report("https://b3.example.com/sub", nil)
+	if !c.saveMirrors() {
+		t.Errorf("saveMirrors() = false after successful fetches")
+	}
+	if c.saveMirrors() {
+		t.Errorf("saveMirrors() = true without changes")
+	}
+	want := map[string]string{"https://a.example.com/sub": "https://a2.example.com/sub", "https://b.example.com/sub": "https://b3.example.com/sub"}
+	if !reflect.DeepEqual(c.LastMirrors, want) {
+		t.Errorf("LastMirrors = %q, want %q", c.LastMirrors, want)
+	}
+
+	//与备份相同，经过 gob 编码和解码后恢复
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode([]*httpCache{c}); err != nil {
+		t.Fatal(err)
+	}
+	var caches []*httpCache
+	if err := gob.NewDecoder(&buf).Decode(&caches); err != nil {
+		t.Fatal(err)
+	}
+	if len(caches) != 1 || !reflect.DeepEqual(caches[0].LastMirrors, want) {
+		t.Fatalf("decoded caches = %+v", caches)
+	}
+	mirrorSets = &sync.Map{}
+	caches[0].restoreMirrors()
+	if got := getMirrorSet(c.Sources[0].URLs()).order(); !reflect.DeepEqual(got, []string{"https://a2.example.com/sub", "https://a.example.com/sub"}) {
+		t.Errorf("order() of A after restore = %q", got)
+	}
+	if got := getMirrorSet(c.Sources[1].URLs()).order(); !reflect.DeepEqual(got, []string{"https://b3.example.com/sub", "https://b.example.com/sub", "https://b2.example.com/sub"}) {
+		t.Errorf("order() of B after restore = %q", got)
+	}
+
+	//只有一个地址时键为 VmessPathUrl
+	single := &httpCache{VmessPathUrl: "https://c.example.com/sub", Mirrors: []string{"https://c2.example.com/sub"}}
+	single.LastMirrors = map[string]string{"https://c.example.com/sub": "https://unknown.example.com/sub"}
+	single.restoreMirrors()
+	if got := getMirrorSet(single.mirrors()[0]).preferred(); got != "" {
+		t.Errorf("restoreMirrors() prefers an url that is no longer a mirror -> %s", got)
+	}
+	getMirrorSet(single.mirrors()[0]).report("https://c2.example.com/sub", nil)
+	if !single.saveMirrors() || single.LastMirrors["https://c.example.com/sub"] != "https://c2.example.com/sub" {
+		t.Errorf("LastMirrors = %q", single.LastMirrors)
+	}
+}
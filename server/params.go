@@ -0,0 +1,161 @@
+package server
+
+import (
+	"fmt"
+	"github.com/lifei6671/clashx-convert/clashx"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//parseOverride 从请求参数中解析覆盖项，没有任何覆盖参数时返回 nil.
+func parseOverride(form url.Values) (*clashx.Override, error) {
+	if !hasAny(form, "port", "socks_port", "allow_lan", "authentication", "hosts",
+		"dns", "dns_ipv6", "enhanced_mode", "fake_ip_range", "fake_ip_filter", "nameserver", "fallback",
+		"tun", "tun_stack", "tun_dns_hijack", "tun_auto_route") {
+		return nil, nil
+	}
+	var err error
+	override := &clashx.Override{}
+	if override.Port, err = formInt(form, "port"); err != nil {
+		return nil, err
+	}
+	if override.SocksPort, err = formInt(form, "socks_port"); err != nil {
+		return nil, err
+	}
+	if override.AllowLan, err = formBool(form, "allow_lan"); err != nil {
+		return nil, err
+	}
+	override.Authentication = formList(form, "authentication")
+	for _, host := range formList(form, "hosts") {
+		kv := strings.SplitN(host, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid hosts, expected domain=ip -> %s", host)
+		}
+		if override.Hosts == nil {
+			override.Hosts = make(map[string]string)
+		}
+		override.Hosts[kv[0]] = kv[1]
+	}
+
+	if hasAny(form, "dns", "dns_ipv6", "enhanced_mode", "fake_ip_range", "fake_ip_filter", "nameserver", "fallback") {
+		dns := &clashx.DNSOverride{
+			EnhancedMode: form.Get("enhanced_mode"),
+			FakeIPRange:  form.Get("fake_ip_range"),
+			FakeIPFilter: formList(form, "fake_ip_filter"),
+			Nameserver:   formList(form, "nameserver"),
+			Fallback:     formList(form, "fallback"),
+		}
+		if dns.Enable, err = formBool(form, "dns"); err != nil {
+			return nil, err
+		}
+		if dns.IPv6, err = formBool(form, "dns_ipv6"); err != nil {
+			return nil, err
+		}
+		override.DNS = dns
+	}
+	if hasAny(form, "tun", "tun_stack", "tun_dns_hijack", "tun_auto_route") {
+		tun := &clashx.TunOverride{
+			Stack:     form.Get("tun_stack"),
+			DNSHijack: formList(form, "tun_dns_hijack"),
+		}
+		if tun.Enable, err = formBool(form, "tun"); err != nil {
+			return nil, err
+		}
+		if tun.AutoRoute, err = formBool(form, "tun_auto_route"); err != nil {
+			return nil, err
+		}
+		override.Tun = tun
+	}
+	if err := override.Validate(); err != nil {
+		return nil, err
+	}
+	return override, nil
+}
+
+//parseOptions 从请求参数中解析节点处理选项，没有任何相关参数时返回 nil.
+func parseOptions(form url.Values) (*clashx.Options, error) {
+	if !hasAny(form, "include", "exclude", "prefix", "emoji", "sort", "region_order", "region_groups", "inline_rule_sets", "collapse_rules", "source_groups", "info_nodes") {
+		return nil, nil
+	}
+	options := &clashx.Options{
+		Include:     form.Get("include"),
+		Exclude:     form.Get("exclude"),
+		Prefix:      form.Get("prefix"),
+		Sort:        form.Get("sort"),
+		RegionOrder: formList(form, "region_order"),
+	}
+	var err error
+	if options.Emoji, err = formBool(form, "emoji"); err != nil {
+		return nil, err
+	}
+	if options.InlineRuleSets, err = formBool(form, "inline_rule_sets"); err != nil {
+		return nil, err
+	}
+	if options.CollapseRules, err = formBool(form, "collapse_rules"); err != nil {
+		return nil, err
+	}
+	if options.SourceGroups, err = formBool(form, "source_groups"); err != nil {
+		return nil, err
+	}
+	if options.InfoNodes, err = formBool(form, "info_nodes"); err != nil {
+		return nil, err
+	}
+	regionGroups, err := formBool(form, "region_groups")
+	if err != nil {
+		return nil, err
+	}
+	if regionGroups != nil && *regionGroups {
+		options.RegionGroups = clashx.DefaultRegionGroups()
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func hasAny(form url.Values, keys ...string) bool {
+	for _, key := range keys {
+		if form.Get(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func formInt(form url.Values, key string) (int, error) {
+	v := form.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s -> %s", key, v)
+	}
+	return i, nil
+}
+
+func formBool(form url.Values, key string) (*bool, error) {
+	v := form.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s -> %s", key, v)
+	}
+	return &b, nil
+}
+
+//formList 解析列表参数，支持重复参数以及逗号分隔.
+func formList(form url.Values, key string) []string {
+	var values []string
+	for _, v := range form[key] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/lifei6671/clashx-convert/clashx"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ruleSetRetryInterval 规则集获取失败后的重试间隔.
+const ruleSetRetryInterval = 5 * time.Minute
+
+//ruleSets 已拉取的规则集，键为规则集的地址、格式和类型.
+var ruleSets = &sync.Map{}
+
+//V2Ray 的 geosite.dat 和 geoip.dat 路径
+var geoSitePath, geoIPPath string
+
+//servedRuleSets 通过 /rules/<name>.yaml 提供的规则集，键为对外的名称.
+var servedRuleSets = &sync.Map{}
+
+type ruleSet struct {
+	lock    sync.RWMutex
+	payload []string
+	err     error
+	ready   chan struct{}
+}
+
+//loadRuleSet 获取规则集的内容，首次获取时同步拉取，之后按规则集自身的间隔在后台更新.
+func loadRuleSet(name string, provider *clashx.RuleProvider) ([]string, error) {
+	rs := &ruleSet{ready: make(chan struct{})}
+	actual, loaded := ruleSets.LoadOrStore(ruleSetKey(provider), rs)
+	rs = actual.(*ruleSet)
+	if !loaded {
+		rs.update(name, provider)
+		close(rs.ready)
+		go autoUpdateRuleSet(rs, name, provider)
+	}
+	<-rs.ready
+
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return rs.payload, rs.err
+}
+
+//update 拉取并解析规则集，失败时保留上一次成功的内容.
+func (rs *ruleSet) update(name string, provider *clashx.RuleProvider) bool {
+	payload, err := fetchRuleSet(provider)
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	if err != nil {
+		log.Printf("Failed to update rule set -> %s %s", name, err)
+		if rs.payload == nil {
+			rs.err = err
+		}
+		return false
+	}
+	log.Printf("rule set update completed -> %s %d", name, len(payload))
+	rs.payload = payload
+	rs.err = nil
+	return true
+}
+
+func ruleSetKey(provider *clashx.RuleProvider) string {
+	return provider.URL + "|" + provider.Path + "|" + provider.Format + "|" + provider.Behavior
+}
+
+//fetchRuleSet 获取并解析规则集，geosite: 和 geoip: 开头的地址从本地的 V2Ray 数据文件中展开.
+func fetchRuleSet(provider *clashx.RuleProvider) ([]string, error) {
+	var body []byte
+	var err error
+	switch {
+	case strings.HasPrefix(provider.URL, clashx.SchemeGeoSite):
+		if geoSitePath == "" {
+			return nil, errors.New("geosite.dat is not configured")
+		}
+		if body, err = ioutil.ReadFile(geoSitePath); err != nil {
+			return nil, err
+		}
+		return clashx.ExpandGeoSite(body, strings.TrimPrefix(provider.URL, clashx.SchemeGeoSite))
+	case strings.HasPrefix(provider.URL, clashx.SchemeGeoIP):
+		if geoIPPath == "" {
+			return nil, errors.New("geoip.dat is not configured")
+		}
+		if body, err = ioutil.ReadFile(geoIPPath); err != nil {
+			return nil, err
+		}
+		return clashx.ExpandGeoIP(body, strings.TrimPrefix(provider.URL, clashx.SchemeGeoIP))
+	case provider.URL != "":
+		body, err = fetch(provider.URL)
+	case provider.Path != "":
+		body, err = ioutil.ReadFile(provider.Path)
+	default:
+		err = errors.New("rule provider url and path are empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return clashx.ParseRuleSet(body, provider.Format, provider.Behavior)
+}
+
+//SetGeoData 设置 geosite: 和 geoip: 规则集使用的 V2Ray 数据文件，每次更新规则集时都会重新读取.
+func SetGeoData(geoSite, geoIP string) {
+	geoSitePath = geoSite
+	geoIPPath = geoIP
+}
+
+//autoUpdateRuleSet 按规则集自身的间隔更新规则集，更新成功后重新生成全部托管配置.
+// 规则集从未获取成功时，按较短的间隔重试.
+func autoUpdateRuleSet(rs *ruleSet, name string, provider *clashx.RuleProvider) {
+	interval := time.Second * time.Duration(provider.RefreshInterval())
+	for {
+		d := interval
+		rs.lock.RLock()
+		if rs.err != nil && d > ruleSetRetryInterval {
+			d = ruleSetRetryInterval
+		}
+		rs.lock.RUnlock()
+		time.Sleep(d)
+		if rs.update(name, provider) {
+			rebuildAll()
+		}
+	}
+}
+
+//rebuildAll 使用已缓存的节点重新生成全部托管配置.
+func rebuildAll() {
+	cache.Range(func(key, value interface{}) bool {
+		if c, ok := value.(*httpCache); ok {
+			c.lock.Lock()
+			if c.proxies != nil {
+				_ = c.update(c.proxies)
+			}
+			c.lock.Unlock()
+		}
+		return true
+	})
+}
+
+//serveRuleSet 登记一个由本服务提供的规则集，返回对外的名称.
+// 对外的名称由规则集名称和来源的摘要组成，不同来源的同名规则集不会冲突，服务重启后名称也保持不变.
+func serveRuleSet(name string, provider *clashx.RuleProvider) string {
+	hash := md5.Sum([]byte(ruleSetKey(provider)))
+	name = name + "-" + hex.EncodeToString(hash[:4])
+	p := *provider
+	servedRuleSets.Store(name, &p)
+	return name
+}
+
+//registerRuleSets 登记全部模板中的远程规则集，服务重启后无需等待配置被重新获取，客户端即可更新规则集.
+func registerRuleSets() {
+	names := append([]string{""}, clashx.TemplateNames()...)
+	for _, templateName := range names {
+		t := clashx.GetTemplate(templateName)
+		if t == nil {
+			continue
+		}
+		for name, p := range t.Config().RuleProviders {
+			if p.URL != "" {
+				serveRuleSet(name, p)
+			}
+		}
+	}
+}
+
+//localRuleProviders 将配置中的远程规则集改为指向本服务，客户端无需直接访问规则集的原始地址.
+// 规则集由本服务转换为 classical 类型的 clash 格式，config 本身不会被修改.
+func localRuleProviders(config *clashx.Config, domain string) *clashx.Config {
+	remote := false
+	for _, p := range config.RuleProviders {
+		if p.URL != "" {
+			remote = true
+			break
+		}
+	}
+	if !remote {
+		return config
+	}
+	config = config.Clone()
+	for name, p := range config.RuleProviders {
+		if p.URL == "" {
+			continue
+		}
+		served := serveRuleSet(name, p)
+		config.RuleProviders[name] = &clashx.RuleProvider{
+			Type:     "http",
+			Behavior: clashx.BehaviorClassical,
+			URL:      domain + "/rules/" + served + ".yaml",
+			Path:     "./ruleset/" + served + ".yaml",
+			Interval: p.Interval,
+		}
+	}
+	return config
+}
+
+//ruleProvider 输出本服务缓存并转换后的规则集.
+func ruleProvider(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(path.Base(r.URL.Path), ".yaml")
+	value, ok := servedRuleSets.Load(name)
+	if !ok {
+		w.WriteHeader(404)
+		_, _ = fmt.Fprint(w, "Rule set does not exist ->"+name)
+		return
+	}
+	payload, err := loadRuleSet(name, value.(*clashx.RuleProvider))
+	if err != nil {
+		w.WriteHeader(502)
+		_, _ = fmt.Fprint(w, err)
+		return
+	}
+	//clash 的规则集无法表达例外规则，例外规则已作为普通规则放在引用该规则集的规则之前
+	payload, _ = clashx.SplitExceptions(payload)
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(struct {
+		Payload []string `yaml:"payload"`
+	}{payload}); err != nil {
+		w.WriteHeader(500)
+		_, _ = fmt.Fprint(w, err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/yaml")
+	_, _ = w.Write(buf.Bytes())
+}
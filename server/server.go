@@ -1,9 +1,9 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
-	"crypto/tls"
 	"encoding/gob"
 	"encoding/hex"
 	"encoding/json"
@@ -27,14 +27,55 @@ import (
 var cache = &sync.Map{}
 var changeChan = make(chan struct{}, 1)
 
+//config 接口支持的输出格式
+const (
+	targetClash = "clash"
+	targetPAC   = "pac"
+)
+
+//defaultOptions 全局的节点处理选项，托管配置未设置的选项使用该值.
+var defaultOptions = &clashx.Options{}
+
 type httpCache struct {
 	Name         string `yaml:"name" json:"name"`
 	ConfigName   string `yaml:"config_name" json:"config_name"`
 	VmessPathUrl string `yaml:"vmess-path-url" json:"vmess_path_url"`
 	Interval     int    `yaml:"interval" json:"interval"`
 	Converter    string `yaml:"converter" json:"converter"`
-	config       *clashx.Config
-	cancel       context.CancelFunc
+	//同一订阅的备用地址，VmessPathUrl 拉取失败时按顺序尝试
+	Mirrors []string `yaml:"mirrors" json:"mirrors"`
+	//多个订阅来源，设置后忽略 VmessPathUrl 和 Converter
+	Sources []*clashx.Source `yaml:"sources" json:"sources"`
+	//托管配置的覆盖项
+	Override *clashx.Override `yaml:"override" json:"override"`
+	//节点处理选项
+	Options *clashx.Options `yaml:"options" json:"options"`
+	//配置模板名称，为空时使用默认模板
+	Template string `yaml:"template" json:"template"`
+	//插入到模板规则之前的自定义规则
+	Rules []string `yaml:"rules" json:"rules"`
+	//手动添加的节点，每项为分享链接或节点的 yaml 片段，每次更新时与订阅节点合并
+	Static []string `yaml:"static" json:"static"`
+	//各订阅最近一次拉取成功的地址，键为订阅的主地址，随备份保存，重启后优先使用
+	LastMirrors map[string]string `yaml:"last_mirrors" json:"last_mirrors"`
+	//保护以下字段以及 Static，更新配置时持有写锁
+	lock sync.RWMutex
+	//最近一次拉取并解析的节点
+	proxies []*clashx.Proxy
+	config  *clashx.Config
+	//最近一次生成配置的校验结果
+	report *buildReport
+	//最近一次拉取的订阅流量信息
+	userInfo *clashx.UserInfo
+	cancel   context.CancelFunc
+}
+
+//buildReport 生成配置的校验结果.
+type buildReport struct {
+	Time     time.Time `json:"time"`
+	Valid    bool      `json:"valid"`
+	Problems []string  `json:"problems,omitempty"`
+	Warnings []string  `json:"warnings,omitempty"`
 }
 
 func Run(ctx context.Context, addr string, path string) error {
@@ -45,6 +86,11 @@ func Run(ctx context.Context, addr string, path string) error {
 	mux.HandleFunc("/config", config)
 	mux.HandleFunc("/single-proxy", singleProxy)
 	mux.HandleFunc("/add-subscribe", addSubscribe)
+	mux.HandleFunc("/templates", templateList)
+	mux.HandleFunc("/rules/", ruleProvider)
+	mux.HandleFunc("/status", status)
+	mux.HandleFunc("/match", match)
+	mux.HandleFunc("/static", staticProxies)
 
 	host, port, _ := net.SplitHostPort(addr)
 	if host == "" {
@@ -55,6 +101,7 @@ func Run(ctx context.Context, addr string, path string) error {
 	server := &http.Server{Addr: addr, Handler: mux}
 
 	initialize(ctx, path)
+	registerRuleSets()
 	go func() {
 		select {
 		case <-ctx.Done():
@@ -82,19 +129,66 @@ func config(w http.ResponseWriter, r *http.Request) {
 		_, _ = fmt.Fprint(w, "name is not null.")
 		return
 	}
+	override, err := parseOverride(r.Form)
+	if err != nil {
+		w.WriteHeader(400)
+		_, _ = fmt.Fprint(w, err)
+		return
+	}
+	options, err := parseOptions(r.Form)
+	if err != nil {
+		w.WriteHeader(400)
+		_, _ = fmt.Fprint(w, err)
+		return
+	}
+	switch target := r.FormValue("target"); target {
+	case "", targetClash, targetPAC:
+	default:
+		w.WriteHeader(400)
+		_, _ = fmt.Fprint(w, "Unsupported target ->"+target)
+		return
+	}
+	templateName := r.FormValue("template")
+	if clashx.GetTemplate(templateName) == nil {
+		w.WriteHeader(400)
+		_, _ = fmt.Fprint(w, "Template does not exist ->"+templateName)
+		return
+	}
 	if content, ok := cache.Load(name); ok {
 		if c, ok := content.(*httpCache); ok {
-			if c.config == nil {
-				config, err := get(c.VmessPathUrl, c.Converter)
+			if c.snapshot() == nil {
+				if err := c.refresh(); err != nil {
+					_, _ = fmt.Fprint(w, err)
+					return
+				}
+			}
+			config := c.snapshot()
+			if options != nil || templateName != "" {
+				if templateName == "" {
+					templateName = c.Template
+				}
+				c.lock.RLock()
+				config, err = c.build(c.proxies, templateName, options)
+				c.lock.RUnlock()
+				if err == nil {
+					err = config.Validate()
+				}
 				if err != nil {
+					w.WriteHeader(500)
 					_, _ = fmt.Fprint(w, err)
 					return
 				}
-				c.config = config
 			}
-			w.Header().Add("Content-Type", "application/yaml")
-			w.Header().Add("Content-Disposition", "attachment; filename=\""+c.ConfigName+".yaml\"")
-			_, _ = fmt.Fprint(w, c.config.String())
+			if override != nil {
+				config = config.Clone()
+				override.Apply(config)
+				if err := config.Validate(); err != nil {
+					w.WriteHeader(500)
+					_, _ = fmt.Fprint(w, err)
+					return
+				}
+			}
+			writeConfig(w, r, c, config)
 			return
 		}
 	} else if urlStr := r.FormValue("url"); urlStr != "" {
@@ -103,30 +197,77 @@ func config(w http.ResponseWriter, r *http.Request) {
 			converter = "vmess"
 		}
 
-		config, err := get(urlStr, converter)
-		if err != nil {
-			log.Printf("Failed to get remote configuration -> %s %s", urlStr, err)
-			_, _ = fmt.Fprint(w, err)
-			return
+		opts := &SubscribeOptions{
+			Converter: converter,
+			Interval:  60,
+			Template:  templateName,
+			Override:  override,
+			Options:   options,
 		}
-		if err := AddVmess(name, converter, urlStr, 60, config); err != nil {
+		if err := AddVmess(name, urlStr, opts); err != nil {
 			_, _ = fmt.Fprint(w, err)
 			return
 		}
-		name, _ := getVmessName(urlStr)
-
-		w.Header().Add("Content-Type", "application/yaml")
-		w.Header().Add("Content-Disposition", "attachment; filename=\""+name+".yaml\"")
-
-		_, _ = fmt.Fprint(w, config.String())
+		if content, ok := cache.Load(name); ok {
+			c := content.(*httpCache)
+			writeConfig(w, r, c, c.snapshot())
+		}
+		return
+	}
+	config := clashx.GetTemplate(templateName).Config()
+	if r.FormValue("target") == targetPAC {
+		writePAC(w, r, name, config)
 		return
 	}
 	w.Header().Add("Content-Type", "application/octet-stream")
 	w.Header().Add("Content-Disposition", "attachment; filename=\""+name+".yaml\"")
-	_, _ = fmt.Fprint(w, clashx.ConfigStr)
+	config.InlineRuleSets(loadRuleSet, false)
+	_, _ = fmt.Fprint(w, localRuleProviders(config, getDomain(r)).String())
 
 }
 
+//writeConfig 按 target 参数输出托管配置，默认输出 clash 配置，响应头中带有订阅的流量信息和更新间隔.
+func writeConfig(w http.ResponseWriter, r *http.Request, c *httpCache, config *clashx.Config) {
+	fileName := c.ConfigName
+	c.lock.RLock()
+	userInfo := c.userInfo
+	c.lock.RUnlock()
+	if userInfo != nil {
+		w.Header().Add("Subscription-Userinfo", userInfo.String())
+	}
+	if c.Interval > 0 {
+		//profile-update-interval 的单位为小时
+		w.Header().Add("Profile-Update-Interval", strconv.Itoa((c.Interval+59)/60))
+	}
+	if r.FormValue("target") == targetPAC {
+		writePAC(w, r, fileName, config)
+		return
+	}
+	config = localRuleProviders(config, getDomain(r))
+	w.Header().Add("Content-Type", "application/yaml")
+	w.Header().Add("Content-Disposition", "attachment; filename=\""+fileName+".yaml\"")
+	_, _ = fmt.Fprint(w, config.String())
+}
+
+//writePAC 展开全部规则集后将配置输出为 PAC 文件，pac_host 参数为 clash 所在的主机，默认为 127.0.0.1.
+func writePAC(w http.ResponseWriter, r *http.Request, fileName string, config *clashx.Config) {
+	host := r.FormValue("pac_host")
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	config = config.Clone()
+	config.InlineRuleSets(loadRuleSet, true)
+	pac, err := config.PAC(host)
+	if err != nil {
+		w.WriteHeader(500)
+		_, _ = fmt.Fprint(w, err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/x-ns-proxy-autoconfig")
+	w.Header().Add("Content-Disposition", "attachment; filename=\""+fileName+".pac\"")
+	_, _ = fmt.Fprint(w, pac)
+}
+
 func singleProxy(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		w.WriteHeader(500)
@@ -147,17 +288,42 @@ func singleProxy(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Add("Content-Disposition", "attachment; filename=\"config.yaml\"")
 
-	_, _ = fmt.Fprint(w, config)
+	config.InlineRuleSets(loadRuleSet, false)
+	_, _ = fmt.Fprint(w, localRuleProviders(config, getDomain(r)))
 	return
 }
 
 func addSubscribe(w http.ResponseWriter, r *http.Request) {
 	type subscribe struct {
-		Port           json.Number `json:"port"`
-		SocksPort      json.Number `json:"socks_port"`
-		AllowLan       bool        `json:"allow_lan"`
-		SubscribeInput string      `json:"subscribe_input"`
-		Interval       json.Number `json:"interval"`
+		Port           json.Number           `json:"port"`
+		SocksPort      json.Number           `json:"socks_port"`
+		AllowLan       bool                  `json:"allow_lan"`
+		SubscribeInput string                `json:"subscribe_input"`
+		Mirrors        []string              `json:"mirrors"`
+		Interval       json.Number           `json:"interval"`
+		Authentication []string              `json:"authentication"`
+		Hosts          map[string]string     `json:"hosts"`
+		DNS            *clashx.DNSOverride   `json:"dns"`
+		Tun            *clashx.TunOverride   `json:"tun"`
+		AutoRegion     bool                  `json:"auto_region"`
+		RegionGroups   []*clashx.RegionGroup `json:"region_groups"`
+		Groups         []*clashx.ProxyGroup  `json:"groups"`
+		Include        string                `json:"include"`
+		Exclude        string                `json:"exclude"`
+		Rename         []*clashx.RenameRule  `json:"rename"`
+		Prefix         string                `json:"prefix"`
+		Emoji          *bool                 `json:"emoji"`
+		Sort           string                `json:"sort"`
+		RegionOrder    []string              `json:"region_order"`
+		Template       string                `json:"template"`
+		InlineRuleSets *bool                 `json:"inline_rule_sets"`
+		CollapseRules  *bool                 `json:"collapse_rules"`
+		SourceGroups   *bool                 `json:"source_groups"`
+		InfoNodes      *bool                 `json:"info_nodes"`
+		Chains         []*clashx.ProxyChain  `json:"chains"`
+		Sources        []*clashx.Source      `json:"sources"`
+		Rules          []string              `json:"rules"`
+		Static         []string              `json:"static"`
 	}
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -172,38 +338,123 @@ func addSubscribe(w http.ResponseWriter, r *http.Request) {
 		_, _ = fmt.Fprint(w, err)
 		return
 	}
-	_, err = url.Parse(model.SubscribeInput)
-	if err != nil {
-		w.WriteHeader(500)
-		_, _ = fmt.Fprint(w, err)
-		return
+	if len(model.Sources) == 0 {
+		for _, urlStr := range append([]string{model.SubscribeInput}, model.Mirrors...) {
+			if _, err := getFetcher(urlStr); err != nil {
+				w.WriteHeader(400)
+				_, _ = fmt.Fprint(w, err)
+				return
+			}
+		}
 	}
 	interval := 2
 	if v, err := model.Interval.Int64(); err == nil && v > 0 {
 		interval = int(v)
 	}
-	name, _ := getVmessName(model.SubscribeInput)
+	subscribeKey := model.SubscribeInput
+	if len(model.Sources) > 0 {
+		urls := make([]string, 0, len(model.Sources))
+		for _, source := range model.Sources {
+			if source.Name == "" {
+				if u, err := url.Parse(source.URL); err == nil {
+					source.Name = u.Hostname()
+				}
+			}
+			err := source.Validate()
+			for _, urlStr := range source.URLs() {
+				if err == nil {
+					_, err = getFetcher(urlStr)
+				}
+			}
+			if err != nil {
+				w.WriteHeader(400)
+				_, _ = fmt.Fprint(w, err)
+				return
+			}
+			urls = append(urls, source.URL)
+		}
+		subscribeKey = strings.Join(urls, "\n")
+	}
+	name, _ := getVmessName(subscribeKey)
 	if _, ok := cache.Load(name); ok {
 		_, _ = fmt.Fprint(w, getDomain(r)+"/config?name="+name)
 		return
 	} else {
 		converter := "vmess"
 
-		config, err := get(model.SubscribeInput, converter)
-		if err != nil {
-			log.Printf("Failed to get remote configuration -> %s %s", model.SubscribeInput, err)
-			w.WriteHeader(500)
-			_, _ = fmt.Fprint(w, err)
-			return
+		override := &clashx.Override{
+			AllowLan:       &model.AllowLan,
+			Authentication: model.Authentication,
+			Hosts:          model.Hosts,
+			DNS:            model.DNS,
+			Tun:            model.Tun,
 		}
-		config.AllowLan = model.AllowLan
 		if port, err := model.Port.Int64(); err == nil && port != 0 {
-			config.Port = int(port)
+			override.Port = int(port)
 		}
 		if port, err := model.SocksPort.Int64(); err == nil && port != 0 {
-			config.SocksPort = int(port)
+			override.SocksPort = int(port)
+		}
+		if err := override.Validate(); err != nil {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, err)
+			return
+		}
+		options := &clashx.Options{
+			Include:        model.Include,
+			Exclude:        model.Exclude,
+			Rename:         model.Rename,
+			Prefix:         model.Prefix,
+			Emoji:          model.Emoji,
+			Sort:           model.Sort,
+			RegionOrder:    model.RegionOrder,
+			RegionGroups:   model.RegionGroups,
+			Groups:         model.Groups,
+			InlineRuleSets: model.InlineRuleSets,
+			CollapseRules:  model.CollapseRules,
+			SourceGroups:   model.SourceGroups,
+			InfoNodes:      model.InfoNodes,
+			Chains:         model.Chains,
+		}
+		if model.AutoRegion && len(options.RegionGroups) == 0 {
+			options.RegionGroups = clashx.DefaultRegionGroups()
+		}
+		if err := options.Validate(); err != nil {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, err)
+			return
 		}
-		if err := AddVmess(name, converter, model.SubscribeInput, interval, config); err != nil {
+		if clashx.GetTemplate(model.Template) == nil {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, "Template does not exist ->"+model.Template)
+			return
+		}
+		if err := clashx.ValidateCustomRules(model.Rules); err != nil {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, err)
+			return
+		}
+		if _, err := parseStatic(model.Static); err != nil {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, err)
+			return
+		}
+		opts := &SubscribeOptions{
+			Converter: converter,
+			Mirrors:   model.Mirrors,
+			Interval:  interval,
+			Template:  model.Template,
+			Override:  override,
+			Options:   options,
+			Rules:     model.Rules,
+			Static:    model.Static,
+		}
+		if len(model.Sources) > 0 {
+			err = AddSources(name, model.Sources, opts)
+		} else {
+			err = AddVmess(name, model.SubscribeInput, opts)
+		}
+		if err != nil {
 			w.WriteHeader(500)
 			_, _ = fmt.Fprint(w, err)
 			return
@@ -214,114 +465,409 @@ func addSubscribe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-//AddVmess 增加一个配置转换.
-func AddVmess(name, converter, urlStr string, interval int, oldConfig *clashx.Config) error {
+//LoadTemplateURL 从远程地址加载一个模板.
+func LoadTemplateURL(name, urlStr string) error {
+	body, err := fetch(urlStr)
+	if err != nil {
+		return err
+	}
+	t, err := clashx.ParseTemplate(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	clashx.RegisterTemplate(name, t)
+	log.Printf("加载模板成功 ->name=%s url=%s\n", name, urlStr)
+	return nil
+}
+
+func templateList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(clashx.TemplateNames())
+}
+
+//SetDefaultOptions 设置全局的节点处理选项.
+func SetDefaultOptions(options *clashx.Options) {
+	defaultOptions = options
+}
+
+//SubscribeOptions 增加托管配置时的设置.
+type SubscribeOptions struct {
+	//订阅格式，默认为 vmess，合并多个订阅来源时使用来源自身的设置
+	Converter string
+	//订阅的备用地址，合并多个订阅来源时使用来源自身的设置
+	Mirrors []string
+	//自动更新频率，单位分钟，不大于 0 时不自动更新
+	Interval int
+	//配置模板名称，为空时使用默认模板
+	Template string
+	Override *clashx.Override
+	Options  *clashx.Options
+	//插入到模板规则之前的自定义规则
+	Rules []string
+	//手动添加的节点
+	Static []string
+}
+
+//AddVmess 增加一个配置转换，opts 为空时使用默认设置.
+func AddVmess(name, urlStr string, opts *SubscribeOptions) error {
+	if opts == nil {
+		opts = &SubscribeOptions{}
+	}
+	converter := opts.Converter
+	if converter == "" {
+		converter = "vmess"
+	}
 	if c := clashx.GetConverter(converter); c == nil {
 		return errors.New("Converter does not exist ->" + converter)
 	}
 	_, configName := getVmessName(urlStr)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	hc := &httpCache{
+	return addCache(&httpCache{
 		Name:         name,
 		ConfigName:   configName,
 		VmessPathUrl: urlStr,
-		Interval:     interval,
+		Mirrors:      opts.Mirrors,
+		Interval:     opts.Interval,
 		Converter:    converter,
-		cancel:       cancel,
-		config:       oldConfig,
+		Template:     opts.Template,
+		Override:     opts.Override,
+		Options:      opts.Options,
+		Rules:        opts.Rules,
+		Static:       opts.Static,
+	})
+}
+
+//AddSources 增加一个合并多个订阅来源的配置转换，opts 为空时使用默认设置.
+func AddSources(name string, sources []*clashx.Source, opts *SubscribeOptions) error {
+	if len(sources) == 0 {
+		return errors.New("sources is empty")
 	}
+	if opts == nil {
+		opts = &SubscribeOptions{}
+	}
+	names := make([]string, 0, len(sources))
+	for _, s := range sources {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		for _, n := range names {
+			if n == s.Name {
+				return errors.New("duplicate source name ->" + s.Name)
+			}
+		}
+		names = append(names, s.Name)
+	}
+	return addCache(&httpCache{
+		Name:       name,
+		ConfigName: strings.Join(names, "+"),
+		Interval:   opts.Interval,
+		Sources:    sources,
+		Template:   opts.Template,
+		Override:   opts.Override,
+		Options:    opts.Options,
+		Rules:      opts.Rules,
+		Static:     opts.Static,
+	})
+}
 
-	config, err := get(hc.VmessPathUrl, hc.Converter)
-	if err != nil {
-		log.Printf("Failed to get remote configuration -> %s %s", hc.VmessPathUrl, err)
-		return err
+//addCache 拉取订阅并托管配置，已存在同名配置时替换.
+func addCache(hc *httpCache) error {
+	if t := clashx.GetTemplate(hc.Template); t == nil {
+		return errors.New("Template does not exist ->" + hc.Template)
 	}
-	log.Println("update completed ->", hc.VmessPathUrl)
-	hc.config = config
-	if oldConfig != nil {
-		hc.config.AllowLan = oldConfig.AllowLan
-		hc.config.Port = oldConfig.Port
-		hc.config.SocksPort = oldConfig.SocksPort
+	ctx, cancel := context.WithCancel(context.Background())
+	hc.cancel = cancel
+
+	if err := hc.refresh(); err != nil {
+		cancel()
+		return err
 	}
 
-	actual, loaded := cache.LoadOrStore(name, hc)
+	actual, loaded := cache.LoadOrStore(hc.Name, hc)
 	if loaded {
 		actual.(*httpCache).cancel()
-		cache.Store(name, hc)
+		cache.Store(hc.Name, hc)
 	}
-	log.Printf("增加配置成功 ->name=%s config_name=%s type=%s url=%s\n", name, configName, converter, urlStr)
+	log.Printf("增加配置成功 ->name=%s config_name=%s type=%s url=%s sources=%d\n", hc.Name, hc.ConfigName, hc.Converter, hc.VmessPathUrl, len(hc.Sources))
 	go autoUpdateConfig(ctx, hc)
 
-	changeChan <- struct{}{}
+	notifyChange()
 	return nil
 }
 
-func autoUpdateConfig(ctx context.Context, c *httpCache) {
-	if c.Interval <= 0 {
-		return
+//notifyChange 通知备份托管配置，已有尚未处理的通知时直接返回，服务启动前调用也不会阻塞.
+func notifyChange() {
+	select {
+	case changeChan <- struct{}{}:
+	default:
 	}
+}
+
+//autoUpdateConfig 按间隔自动更新托管配置，订阅来源中有本地文件时，文件变化后也会立即更新.
+func autoUpdateConfig(ctx context.Context, c *httpCache) {
+	var tick, watch <-chan time.Time
 	d := time.Minute * time.Duration(c.Interval)
 	timer := time.NewTimer(d)
 	defer timer.Stop()
+	if c.Interval > 0 {
+		tick = timer.C
+	}
+	if c.watchable() {
+		ticker := time.NewTicker(fileWatchInterval)
+		defer ticker.Stop()
+		watch = ticker.C
+	}
+	if tick == nil && watch == nil {
+		return
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			log.Printf("Automatic update has stopped ->cancel %s %s \n", c.Name, c.VmessPathUrl)
 			return
-		case <-timer.C:
+		case <-tick:
 			if _, ok := cache.Load(c.Name); !ok {
 				log.Printf("Automatic update has stopped ->!ok %s %s \n", c.Name, c.VmessPathUrl)
 				return
 			}
-			config, err := get(c.VmessPathUrl, c.Converter)
-			if err != nil {
-				log.Printf("Failed to get remote configuration -> %s %s", c.VmessPathUrl, err)
-				break
+			_ = c.refresh()
+			timer.Reset(d)
+		case <-watch:
+			if _, ok := cache.Load(c.Name); !ok {
+				log.Printf("Automatic update has stopped ->!ok %s %s \n", c.Name, c.VmessPathUrl)
+				return
 			}
-			log.Println("update completed ->", c.VmessPathUrl)
-			oldConfig := c.config
-			c.config = config
-			if oldConfig != nil {
-				c.config.AllowLan = oldConfig.AllowLan
-				c.config.Port = oldConfig.Port
-				c.config.SocksPort = oldConfig.SocksPort
+			if c.changed() {
+				log.Println("Subscription changed ->", c.Name)
+				_ = c.refresh()
 			}
-			timer.Reset(d)
 		}
 	}
 }
 
-func get(urlStr, converter string) (*clashx.Config, error) {
+//mirrors 托管配置的每个订阅的全部地址，每组的首个为主地址，其后为备用地址.
+func (c *httpCache) mirrors() [][]string {
+	if len(c.Sources) == 0 {
+		return [][]string{append([]string{c.VmessPathUrl}, c.Mirrors...)}
+	}
+	mirrors := make([][]string, 0, len(c.Sources))
+	for _, s := range c.Sources {
+		mirrors = append(mirrors, s.URLs())
+	}
+	return mirrors
+}
+
+//urls 托管配置的全部订阅地址，包括备用地址.
+func (c *httpCache) urls() []string {
+	var urls []string
+	for _, m := range c.mirrors() {
+		urls = append(urls, m...)
+	}
+	return urls
+}
+
+//watchable 是否有订阅地址支持检测内容变化.
+func (c *httpCache) watchable() bool {
+	for _, urlStr := range c.urls() {
+		if f, err := getFetcher(urlStr); err == nil {
+			if _, ok := f.(changeDetector); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+//changed 是否有订阅地址的内容自上次拉取后发生了变化.
+func (c *httpCache) changed() bool {
+	for _, urlStr := range c.urls() {
+		if f, err := getFetcher(urlStr); err == nil {
+			if d, ok := f.(changeDetector); ok && d.Changed(urlStr) {
+				return true
+			}
+		}
 	}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Get(urlStr)
+	return false
+}
+
+//snapshot 返回当前生效的配置，尚未生成时返回 nil.
+func (c *httpCache) snapshot() *clashx.Config {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.config
+}
 
+//refresh 重新拉取订阅并应用覆盖项.
+func (c *httpCache) refresh() error {
+	proxies, info, err := c.fetchProxies()
 	if err != nil {
-		log.Println("Failed to get remote response ->", err)
-		return nil, err
+		log.Printf("Failed to get remote configuration -> %s %s %s", c.Name, c.VmessPathUrl, err)
+		return err
 	}
-	defer resp.Body.Close()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.userInfo = info
+	if c.saveMirrors() {
+		notifyChange()
+	}
+	if err := c.update(proxies); err != nil {
+		return err
+	}
+	log.Println("update completed ->", c.Name, c.VmessPathUrl)
+	return nil
+}
+
+//fetchProxies 拉取全部订阅来源的节点以及合并后的流量信息，任一来源失败时返回错误，以免用缺少节点的配置替换上一次的配置.
+func (c *httpCache) fetchProxies() ([]*clashx.Proxy, *clashx.UserInfo, error) {
+	if len(c.Sources) == 0 {
+		return get(c.mirrors()[0], c.Converter)
+	}
+	var proxies []*clashx.Proxy
+	var info *clashx.UserInfo
+	for _, s := range c.Sources {
+		items, sourceInfo, err := get(s.URLs(), s.ConverterName())
+		if err != nil {
+			return nil, nil, fmt.Errorf("source %s -> %s", s.Name, err)
+		}
+		proxies = append(proxies, s.Apply(items)...)
+		info = info.Merge(sourceInfo)
+	}
+	return proxies, info, nil
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+//update 使用指定节点重新生成配置，生成的配置校验不通过时保留上一次可用的配置，调用方需要持有写锁.
+func (c *httpCache) update(proxies []*clashx.Proxy) error {
+	report := &buildReport{Time: time.Now(), Valid: true}
+	config, err := c.build(proxies, c.Template, nil)
+	if err == nil {
+		report.Warnings = config.Warnings
+		err = config.Validate()
+	}
 	if err != nil {
-		log.Printf("Failed to read remote response -> %s %s", urlStr, err)
-		return nil, err
+		report.Valid = false
+		if e, ok := err.(*clashx.ValidationError); ok {
+			report.Problems = e.Problems
+		} else {
+			report.Problems = []string{err.Error()}
+		}
+		c.report = report
+		if c.config != nil {
+			log.Printf("Invalid configuration, keep the last good one -> %s %s", c.Name, err)
+		} else {
+			log.Printf("Failed to build configuration -> %s %s", c.VmessPathUrl, err)
+		}
+		return err
+	}
+	for _, warning := range config.Warnings {
+		log.Printf("Conversion warning -> %s %s", c.Name, warning)
 	}
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Failed to read remote response -> %s http_code=%d body=%s", urlStr, resp.StatusCode, string(body))
-		return nil, fmt.Errorf("http_code=%d", resp.StatusCode)
+	c.report = report
+	c.proxies = proxies
+	c.config = config
+	return nil
+}
+
+//build 使用指定模板和托管配置的选项生成配置，extra 中的选项优先于托管配置的选项.
+func (c *httpCache) build(proxies []*clashx.Proxy, template string, extra *clashx.Options) (*clashx.Config, error) {
+	tpl := clashx.GetTemplate(template)
+	if tpl == nil {
+		return nil, errors.New("Template does not exist ->" + template)
 	}
-	config, err := clashx.GetConverter(converter).Convert(string(body))
+	static, err := parseStatic(c.Static)
+	if err != nil {
+		return nil, err
+	}
+	proxies = append(proxies[:len(proxies):len(proxies)], static...)
+	options := defaultOptions.Merge(c.Options).Merge(extra)
+	config, err := clashx.Build(tpl, proxies, options)
 	if err != nil {
-		log.Println("Format conversion failed ->", err)
 		return nil, err
 	}
+	if options != nil && options.InfoNodes != nil && *options.InfoNodes {
+		config.AddUserInfoProxies(c.userInfo)
+	}
+	if err := config.PrependRules(c.Rules); err != nil {
+		return nil, err
+	}
+	config.InlineRuleSets(loadRuleSet, options != nil && options.InlineRuleSets != nil && *options.InlineRuleSets)
+	config.OptimizeRules(options != nil && options.CollapseRules != nil && *options.CollapseRules)
+	c.Override.Apply(config)
 	return config, nil
+}
+
+//status 输出托管配置最近一次生成的校验结果.
+func status(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	content, ok := cache.Load(name)
+	if !ok {
+		w.WriteHeader(404)
+		_, _ = fmt.Fprint(w, "Config does not exist ->"+name)
+		return
+	}
+	c := content.(*httpCache)
+	c.lock.RLock()
+	report := c.report
+	c.lock.RUnlock()
+	w.Header().Add("Content-Type", "application/json")
+	var mirrors []*mirrorHealth
+	for _, urls := range c.mirrors() {
+		mirrors = append(mirrors, getMirrorSet(urls).status()...)
+	}
+	_ = json.NewEncoder(w).Encode(struct {
+		Name    string          `json:"name"`
+		Report  *buildReport    `json:"report"`
+		Mirrors []*mirrorHealth `json:"mirrors"`
+	}{c.Name, report, mirrors})
+}
+
+//get 按顺序尝试订阅的各个镜像地址，使用第一个拉取并解析成功的结果，同时返回订阅响应中的流量信息.
+// 最近一次成功的地址会被优先尝试.
+func get(urls []string, converter string) ([]*clashx.Proxy, *clashx.UserInfo, error) {
+	set := getMirrorSet(urls)
+	var problems []string
+	for _, urlStr := range set.order() {
+		proxies, info, err := getURL(urlStr, converter)
+		set.report(urlStr, err)
+		if err == nil {
+			if urlStr != urls[0] {
+				log.Printf("Using subscription mirror -> %s %s", urls[0], urlStr)
+			}
+			return proxies, info, nil
+		}
+		if len(urls) == 1 {
+			return nil, nil, err
+		}
+		problems = append(problems, fmt.Sprintf("%s %s", urlStr, err))
+	}
+	return nil, nil, fmt.Errorf("all mirrors failed -> %s", strings.Join(problems, "; "))
+}
 
+//getURL 拉取一个订阅地址并解析节点.
+func getURL(urlStr, converter string) ([]*clashx.Proxy, *clashx.UserInfo, error) {
+	body, header, err := fetchWithHeader(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxies, err := clashx.GetConverter(converter).Convert(string(body))
+	if err != nil {
+		log.Println("Format conversion failed ->", err)
+		return nil, nil, err
+	}
+	return proxies, clashx.ParseUserInfo(header.Get("Subscription-Userinfo")), nil
+}
+
+//fetch 获取地址的内容.
+func fetch(urlStr string) ([]byte, error) {
+	body, _, err := fetchWithHeader(urlStr)
+	return body, err
+}
+
+//fetchWithHeader 使用地址对应的 Fetcher 获取内容以及响应头，非 HTTP(S) 地址的响应头为空.
+func fetchWithHeader(urlStr string) ([]byte, http.Header, error) {
+	f, err := getFetcher(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f.Fetch(urlStr)
 }
 
 func getDomain(r *http.Request) string {
@@ -382,7 +928,14 @@ func initialize(ctx context.Context, path string) {
 				if len(caches) > 0 {
 					if f, err := os.Create(path); err == nil {
 						encoder := gob.NewEncoder(f)
-						if err := encoder.Encode(caches); err != nil {
+						for _, c := range caches {
+							c.lock.RLock()
+						}
+						err := encoder.Encode(caches)
+						for _, c := range caches {
+							c.lock.RUnlock()
+						}
+						if err != nil {
 							log.Println("备份失败 ->", err, path)
 							break
 						}
@@ -402,9 +955,9 @@ func initialize(ctx context.Context, path string) {
 		}
 		for _, c := range caches {
 			ctx1, cancel := context.WithCancel(ctx)
-			tmp := *c
-			tmp.cancel = cancel
-			cache.Store(c.Name, &tmp)
+			c.cancel = cancel
+			c.restoreMirrors()
+			cache.Store(c.Name, c)
 			log.Printf("恢复备份成功 ->%s - %s\n", c.Name, path)
 			go autoUpdateConfig(ctx1, c)
 		}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/lifei6671/clashx-convert/clashx"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testVmessLink(name, server string) string {
+	return "vmess://" + base64.StdEncoding.EncodeToString([]byte(`{"v":"2","ps":"`+name+`","add":"`+server+`","port":"443","id":"b831381d-6324-4d53-ad4f-8cda48b30811","aid":"0","net":"ws","type":"auto","path":"/ray","tls":"tls"}`))
+}
+
+//removeTestCache 移除测试添加的托管配置并停止自动更新.
+func removeTestCache(name string) {
+	if v, ok := cache.Load(name); ok {
+		v.(*httpCache).cancel()
+		cache.Delete(name)
+	}
+}
+
+func TestAddVmessWithoutBackup(t *testing.T) {
+	//服务启动前没有协程读取 changeChan，添加配置不能阻塞
+	select {
+	case <-changeChan:
+	default:
+	}
+	names := []string{"test-add-1", "test-add-2"}
+	done := make(chan error, 1)
+	go func() {
+		for i, name := range names {
+			opts := &SubscribeOptions{Template: "minimal"}
+			if err := AddVmess(name, "inline:"+testVmessLink("香港 01", fmt.Sprintf("10.0.0.%d", i+1)), opts); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddVmess blocks when nothing reads changeChan")
+	}
+	for _, name := range names {
+		if v, ok := cache.Load(name); !ok || v.(*httpCache).snapshot() == nil {
+			t.Errorf("config is not added -> %s", name)
+		}
+		removeTestCache(name)
+	}
+	if len(changeChan) != 1 {
+		t.Errorf("pending backup notifications = %d, want 1", len(changeChan))
+	}
+}
+
+func TestSingleProxyInlinesRuleSets(t *testing.T) {
+	tpl, err := clashx.ParseTemplate(strings.NewReader(`port: 7890
+rule-providers:
+  ads:
+    type: http
+    behavior: classical
+    format: adblock
+    url: "data:,%7C%7Cads.example.com%5E"
+    inline: true
+Rule:
+  - RULE-SET,ads,REJECT
+  - MATCH,Proxy
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultTemplate := clashx.DefaultTemplate
+	clashx.DefaultTemplate = tpl
+	defer func() { clashx.DefaultTemplate = defaultTemplate }()
+
+	r := httptest.NewRequest("POST", "/single-proxy", strings.NewReader(url.Values{"single_proxy": {testVmessLink("香港 01", "10.0.0.1")}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	singleProxy(w, r)
+	body := w.Body.String()
+	if w.Code != 200 {
+		t.Fatalf("code = %d, body = %s", w.Code, body)
+	}
+	if strings.Contains(body, "RULE-SET") || !strings.Contains(body, "DOMAIN-SUFFIX,ads.example.com,REJECT") {
+		t.Errorf("rule set is not inlined:\n%s", body)
+	}
+}
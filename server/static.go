@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"github.com/lifei6671/clashx-convert/clashx"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+//parseStatic 解析托管配置中手动添加的节点.
+func parseStatic(entries []string) ([]*clashx.Proxy, error) {
+	var proxies []*clashx.Proxy
+	for _, entry := range entries {
+		items, err := clashx.ParseStaticProxies(entry)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, items...)
+	}
+	return proxies, nil
+}
+
+//staticSeparator 多个手动添加的节点条目之间的分隔行.
+const staticSeparator = "---"
+
+//splitStatic 按单独一行的 --- 拆分手动添加的节点条目，与 GET 输出的格式相同.
+func splitStatic(text string) []string {
+	var entries []string
+	var lines []string
+	flush := func() {
+		if entry := strings.TrimSpace(strings.Join(lines, "\n")); entry != "" {
+			entries = append(entries, entry)
+		}
+		lines = lines[:0]
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == staticSeparator {
+			flush()
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, "\r"))
+	}
+	flush()
+	return entries
+}
+
+//staticProxies 查看或替换托管配置中手动添加的节点.
+//GET 输出当前的节点，条目之间以单独一行的 --- 分隔；POST 使用相同格式的请求体替换全部节点，
+// 每个条目为每行一个的分享链接或节点的 yaml 片段，请求体为空时清除全部节点.
+func staticProxies(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	content, ok := cache.Load(name)
+	if !ok {
+		w.WriteHeader(404)
+		_, _ = fmt.Fprint(w, "Config does not exist ->"+name)
+		return
+	}
+	c := content.(*httpCache)
+	switch r.Method {
+	case http.MethodGet:
+		c.lock.RLock()
+		static := c.Static
+		c.lock.RUnlock()
+		w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+		_, _ = fmt.Fprint(w, strings.Join(static, "\n"+staticSeparator+"\n"))
+	case http.MethodPost, http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, "解析请求数据失败")
+			return
+		}
+		static := splitStatic(string(body))
+		if _, err := parseStatic(static); err != nil {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, err)
+			return
+		}
+		c.lock.Lock()
+		previous, report := c.Static, c.report
+		c.Static = static
+		err = c.update(c.proxies)
+		if err != nil {
+			c.Static, c.report = previous, report
+		}
+		c.lock.Unlock()
+		if err != nil {
+			w.WriteHeader(400)
+			_, _ = fmt.Fprint(w, err)
+			return
+		}
+		notifyChange()
+		_, _ = fmt.Fprint(w, getDomain(r)+"/config?name="+name)
+	default:
+		w.WriteHeader(405)
+	}
+}